@@ -1,18 +1,36 @@
 package gee
 
 import (
+	"context"
 	"errors"
 	"gee/binding"
 	"gee/render"
+	"math"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type H map[string]interface{}
 
+// Context carries the request and the response of one request through the
+// handler chain. Contexts are pooled and reused by the engine, a Context must
+// not be used after its handler returned. Handlers which pass the context to
+// another goroutine have to pass a copy, see Copy.
+//
+// When the handler returns, the context is released: until it is reused, its
+// accessors and its Writer panic, and as a context.Context it is done. A use
+// after the context was reused for another request can only be detected by
+// the race detector.
 type Context struct {
+	writermem responseWriter
+
 	// origin object
 	Writer ResponseWriter
 	Req    *http.Request
@@ -20,7 +38,10 @@ type Context struct {
 	// request info
 	Path   string
 	Method string
-	Params map[string]string
+	Params Params
+
+	// fullPath is the pattern of the matched route
+	fullPath string
 
 	// response info
 	StatusCode int
@@ -35,9 +56,21 @@ type Context struct {
 	handlers []HandlerFunc
 	index    int
 
+	// depth is the nesting of Engine.HandleContext calls
+	depth int
+
+	// Errors are the errors collected by the handlers, see Error.
+	Errors Errors
+
+	// sameSite is the SameSite attribute of the cookies set by SetCookie
+	sameSite http.SameSite
+
 	// engine pointer
 	engine *Engine
 
+	// released is set while the context is pooled, see release
+	released int32
+
 	// queryCache store the requested all query string.
 	queryCache url.Values
 
@@ -47,18 +80,108 @@ type Context struct {
 }
 
 func newContext(w http.ResponseWriter, req *http.Request) *Context {
-	nw := &responseWriter{}
-	nw.reset(w)
-	return &Context{
-		Writer: nw,
-		Req:    req,
-		Path:   req.URL.Path,
-		Method: req.Method,
-		Params: make(map[string]string),
-		index:  -1,
+	c := &Context{}
+	c.writermem.reset(w)
+	c.Req = req
+	c.reset()
+	return c
+}
+
+// reset prepares the context for serving c.Req, after its writer was reset.
+// The backing array of the params is reused.
+func (c *Context) reset() {
+	atomic.StoreInt32(&c.released, 0)
+	c.Writer = &c.writermem
+	c.Path = c.Req.URL.Path
+	c.Method = c.Req.Method
+	c.Params = c.Params[:0]
+	c.fullPath = ""
+	c.StatusCode = 0
+	c.Keys = nil
+	c.handlers = nil
+	c.index = -1
+	c.depth = 0
+	c.Errors = nil
+	c.sameSite = 0
+	c.queryCache = nil
+	c.formCache = nil
+}
+
+// errContextReleased is the panic value of the use of a released context.
+var errContextReleased = errors.New("gee: Context used after its handler returned, pass a Copy to other goroutines")
+
+// release marks the context as unused when its handler returned, it drops the
+// references to the request and the response.
+func (c *Context) release() {
+	c.mu.Lock()
+	atomic.StoreInt32(&c.released, 1)
+	c.Keys = nil
+	c.mu.Unlock()
+
+	c.Req = nil
+	c.Writer = releasedWriter{}
+	c.writermem.ResponseWriter = nil
+	c.Path = ""
+	c.Method = ""
+	c.Params = c.Params[:0]
+	c.fullPath = ""
+	c.handlers = nil
+	c.Errors = nil
+	c.queryCache = nil
+	c.formCache = nil
+}
+
+// isReleased reports whether the context is pooled.
+func (c *Context) isReleased() bool {
+	return atomic.LoadInt32(&c.released) != 0
+}
+
+// mustBeActive panics if the context is used after it was released.
+func (c *Context) mustBeActive() {
+	if c.isReleased() {
+		panic(errContextReleased)
 	}
 }
 
+// Copy returns a copy of the context that can be used outside of the request
+// scope, like in a goroutine started by the handler. The copy has no handlers
+// and must not write the response.
+func (c *Context) Copy() *Context {
+	c.mustBeActive()
+	cp := &Context{
+		Req:        c.Req,
+		Path:       c.Path,
+		Method:     c.Method,
+		fullPath:   c.fullPath,
+		StatusCode: c.StatusCode,
+		index:      -1,
+		engine:     c.engine,
+		queryCache: c.queryCache,
+		formCache:  c.formCache,
+	}
+	cp.writermem = c.writermem
+	cp.writermem.ResponseWriter = nil
+	cp.Writer = &cp.writermem
+
+	cp.Params = make(Params, len(c.Params))
+	copy(cp.Params, c.Params)
+
+	if len(c.Errors) > 0 {
+		cp.Errors = make(Errors, len(c.Errors))
+		copy(cp.Errors, c.Errors)
+	}
+
+	c.mu.RLock()
+	if c.Keys != nil {
+		cp.Keys = make(map[string]interface{}, len(c.Keys))
+		for k, v := range c.Keys {
+			cp.Keys[k] = v
+		}
+	}
+	c.mu.RUnlock()
+	return cp
+}
+
 /**********************************************************/
 /******************* METADATA MANAGEMENT ******************/
 /**********************************************************/
@@ -67,19 +190,21 @@ func newContext(w http.ResponseWriter, req *http.Request) *Context {
 // It also lazy initializes c.Keys if it was not used proviously.
 func (c *Context) Set(key string, value interface{}) {
 	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mustBeActive()
 	if c.Keys == nil {
 		c.Keys = make(map[string]interface{})
 	}
 	c.Keys[key] = value
-	c.mu.Unlock()
 }
 
 // Get returns the value for the given key, ie: (value, true)
 // If the value does not exist it returns (nil, false)
 func (c *Context) Get(key string) (value interface{}, exists bool) {
 	c.mu.RLock()
+	defer c.mu.RUnlock()
+	c.mustBeActive()
 	value, exists = c.Keys[key]
-	c.mu.RUnlock()
 	return
 }
 
@@ -127,15 +252,85 @@ func (c *Context) GetInt64(key string) (i64 int64) {
 /********************* INPUT DATA ********************/
 /*****************************************************/
 
+// FullPath returns the pattern of the matched route, like "/users/:id".
+// It returns an empty string if no route was matched.
+func (c *Context) FullPath() string {
+	c.mustBeActive()
+	return c.fullPath
+}
+
 // Param returns the value of the URL param.
 func (c *Context) Param(key string) string {
-	return c.Params[key]
+	c.mustBeActive()
+	return c.Params.ByName(key)
+}
+
+// ParamInt returns the value of the URL param as an int,
+// it fails if the param is missing or not a valid integer.
+func (c *Context) ParamInt(key string) (int, error) {
+	return strconv.Atoi(c.Param(key))
+}
+
+// ParamInt64 returns the value of the URL param as an int64,
+// it fails if the param is missing or not a valid integer.
+func (c *Context) ParamInt64(key string) (int64, error) {
+	return strconv.ParseInt(c.Param(key), 10, 64)
+}
+
+// ParamUUID returns the value of the URL param as a UUID,
+// it fails if the param is missing or not a valid UUID.
+func (c *Context) ParamUUID(key string) (UUID, error) {
+	return parseUUID(c.Param(key))
 }
 
 // AddParam adds param to context and
 // replaces path param key with given value for e2e testing purpose.
 func (c *Context) AddParam(key, value string) {
-	c.Params[key] = value
+	for i := range c.Params {
+		if c.Params[i].Key == key {
+			c.Params[i].Value = value
+			return
+		}
+	}
+	c.Params = append(c.Params, Param{Key: key, Value: value})
+}
+
+// ClientIP returns the IP of the client. The header of the TrustedPlatform is
+// used first if it is set. If the request comes from a trusted proxy, see
+// Engine.SetTrustedProxies, the RemoteIPHeaders are read in order, walking
+// each from the right to the first IP that is not a trusted proxy.
+// Otherwise it is the RemoteIP.
+func (c *Context) ClientIP() string {
+	c.mustBeActive()
+	e := c.engine
+	if e.TrustedPlatform != "" {
+		if ip := c.Req.Header.Get(e.TrustedPlatform); ip != "" {
+			return ip
+		}
+	}
+
+	remoteIP := net.ParseIP(c.RemoteIP())
+	if remoteIP == nil {
+		return ""
+	}
+	if e.isTrustedProxy(remoteIP) {
+		for _, header := range e.RemoteIPHeaders {
+			if ip, ok := e.clientIPFromHeader(c.Req.Header.Get(header)); ok {
+				return ip
+			}
+		}
+	}
+	return remoteIP.String()
+}
+
+// RemoteIP returns the IP of the connection the request came from,
+// without the port.
+func (c *Context) RemoteIP() string {
+	ip, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		return ""
+	}
+	return ip
 }
 
 // Query returns the keyed url query value if it exists,
@@ -183,6 +378,7 @@ func (c *Context) initQueryCache() {
 
 // GetQueryArray returns the value of associated key,
 func (c *Context) GetQueryArray(key string) (values []string, ok bool) {
+	c.mustBeActive()
 	c.initQueryCache()
 	values, ok = c.queryCache[key]
 	return
@@ -225,15 +421,26 @@ func (c *Context) initFormCache() {
 // GetPostFormArray returns a slice of strings of a given form key, plus
 // a boolean value whether at least one value exists for the given key.
 func (c *Context) GetPostFormArray(key string) (values []string, ok bool) {
+	c.mustBeActive()
 	c.initFormCache()
 	values, ok = c.formCache[key]
 	return
 }
 
 // ShouldBindWith binds the http passed struct pointer using the specified binding engine.
-// See the binding package.
+// See the binding package. A failure is also collected in c.Errors, with
+// ErrorTypeBind unless it is an *Error already.
 func (c *Context) shouldBindWith(obj interface{}, b binding.Binding) error {
-	return b.Bind(c.Req, obj)
+	if err := b.Bind(c.Req, obj); err != nil {
+		if _, ok := err.(*Error); ok {
+			// keep the type given by the binding
+			c.Error(err)
+		} else {
+			c.Error(err).SetType(ErrorTypeBind)
+		}
+		return err
+	}
+	return nil
 }
 
 // ShouldBindJSON is a shortcut for c.shouldBindWith(obj, binding.JSON).
@@ -260,6 +467,122 @@ func (c *Context) MultipartForm() (*multipart.Form, error) {
 	return c.Req.MultipartForm, err
 }
 
+/**************************************************/
+/******************** COOKIES *********************/
+/**************************************************/
+
+// SetSameSite sets the SameSite attribute of the cookies set afterwards.
+func (c *Context) SetSameSite(samesite http.SameSite) {
+	c.sameSite = samesite
+}
+
+// SetCookie adds a Set-Cookie header to the response. The value is query
+// escaped, path defaults to "/". A maxAge of zero makes a session cookie, a
+// negative one deletes the cookie.
+func (c *Context) SetCookie(name, value string, maxAge int, path, domain string, secure, httpOnly bool) {
+	if path == "" {
+		path = "/"
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    url.QueryEscape(value),
+		MaxAge:   maxAge,
+		Path:     path,
+		Domain:   domain,
+		SameSite: c.sameSite,
+		Secure:   secure,
+		HttpOnly: httpOnly,
+	})
+}
+
+// Cookie returns the unescaped value of the named request cookie,
+// it fails with http.ErrNoCookie if there is no such cookie and with a
+// url.EscapeError if the value is not escaped properly.
+func (c *Context) Cookie(name string) (string, error) {
+	c.mustBeActive()
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return url.QueryUnescape(cookie.Value)
+}
+
+// secureCookie returns the value of the named request cookie for the secure
+// cookie methods, which take a malformed value for a tampered one.
+func (c *Context) secureCookie(name string) (string, error) {
+	value, err := c.Cookie(name)
+	var escapeErr url.EscapeError
+	if errors.As(err, &escapeErr) {
+		return "", ErrCookieTampered
+	}
+	return value, err
+}
+
+// cookieExpiry returns the expiry time stored in a secure cookie value.
+func cookieExpiry(maxAge int) time.Time {
+	if maxAge <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(maxAge) * time.Second)
+}
+
+// SetSignedCookie is like SetCookie, but signs the value with the
+// SecureCookie of the engine. For a positive maxAge the signed value expires
+// as well, so it is rejected even if the client keeps the cookie longer.
+func (c *Context) SetSignedCookie(name, value string, maxAge int, path, domain string, secure, httpOnly bool) error {
+	if c.engine.SecureCookie == nil {
+		return ErrNoSecureCookie
+	}
+	signed, err := c.engine.SecureCookie.Sign(name, value, cookieExpiry(maxAge))
+	if err != nil {
+		return err
+	}
+	c.SetCookie(name, signed, maxAge, path, domain, secure, httpOnly)
+	return nil
+}
+
+// SignedCookie returns the value of the named request cookie set with
+// SetSignedCookie. It fails with ErrCookieTampered if the signature is
+// invalid and with ErrCookieExpired if the value expired.
+func (c *Context) SignedCookie(name string) (string, error) {
+	if c.engine.SecureCookie == nil {
+		return "", ErrNoSecureCookie
+	}
+	signed, err := c.secureCookie(name)
+	if err != nil {
+		return "", err
+	}
+	return c.engine.SecureCookie.Verify(name, signed)
+}
+
+// SetEncryptedCookie is like SetSignedCookie, but encrypts the value, so
+// that the client can not read it either.
+func (c *Context) SetEncryptedCookie(name, value string, maxAge int, path, domain string, secure, httpOnly bool) error {
+	if c.engine.SecureCookie == nil {
+		return ErrNoSecureCookie
+	}
+	encrypted, err := c.engine.SecureCookie.Encrypt(name, value, cookieExpiry(maxAge))
+	if err != nil {
+		return err
+	}
+	c.SetCookie(name, encrypted, maxAge, path, domain, secure, httpOnly)
+	return nil
+}
+
+// EncryptedCookie returns the value of the named request cookie set with
+// SetEncryptedCookie. It fails with ErrCookieTampered if the value can not
+// be decrypted and with ErrCookieExpired if it expired.
+func (c *Context) EncryptedCookie(name string) (string, error) {
+	if c.engine.SecureCookie == nil {
+		return "", ErrNoSecureCookie
+	}
+	encrypted, err := c.secureCookie(name)
+	if err != nil {
+		return "", err
+	}
+	return c.engine.SecureCookie.Decrypt(name, encrypted)
+}
+
 /**************************************************/
 /************ RESPONSE RENDERING ******************/
 /**************************************************/
@@ -293,6 +616,7 @@ func (c *Context) Render(code int, r render.Render) {
 	}
 
 	if err := r.Render(c.Writer); err != nil {
+		c.Error(err).SetType(ErrorTypeRender)
 		panic(err)
 	}
 }
@@ -308,7 +632,7 @@ func (c *Context) IndentedJSON(code int, obj interface{}) {
 }
 
 func (c *Context) SecureJSON(code int, obj interface{}) {
-	c.Render(code, render.SecureJSON{Data: obj, Prefix: "prefix"})
+	c.Render(code, render.SecureJSON{Data: obj, Prefix: c.engine.secureJSONPrefix})
 }
 
 func (c *Context) JSONP(code int, obj interface{}) {
@@ -336,19 +660,180 @@ func (c *Context) String(code int, format string, obj ...interface{}) {
 	c.Render(code, render.String{Format: format, Data: obj})
 }
 
+// Fail collects err and writes the status text of code. Only the message of
+// an error with ErrorTypePublic is sent to the client instead.
 func (c *Context) Fail(code int, err error) {
-	c.Status(code)
-	c.Writer.Write([]byte(err.Error()))
+	msg := c.Error(err)
+	if msg.IsType(ErrorTypePublic) {
+		c.String(code, msg.Error())
+		return
+	}
+	c.String(code, http.StatusText(code))
 }
 
 func (c *Context) HTML(code int, name string, data interface{}) {
 	c.Render(code, render.HTML{Template: c.engine.htmlTemplates, Name: name, Data: data})
 }
 
+// Next runs the pending handlers of the chain, it is meant to be called by
+// middleware that does work after the handlers following it. The chain stops
+// once a handler aborted, see Abort.
 func (c *Context) Next() {
+	c.mustBeActive()
 	c.index++
 	for c.index < len(c.handlers) {
 		c.handlers[c.index](c)
 		c.index++
 	}
 }
+
+/************************************/
+/********** FLOW CONTROL ************/
+/************************************/
+
+// abortIndex is set as the handler index by Abort, it is beyond the end of
+// any handler chain, so Next runs no more handlers.
+const abortIndex int = math.MaxInt >> 1
+
+// IsAborted reports whether the chain was aborted.
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// Abort prevents the pending handlers of the chain from being called, like
+// the route handler after an authorization middleware rejected the request.
+// The current handler continues, as does the code after c.Next() in the
+// middleware that ran before.
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// AbortWithStatus calls Abort and writes the header with the status code.
+func (c *Context) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Writer.WriteHeaderNow()
+	c.Abort()
+}
+
+// AbortWithStatusJSON calls Abort and renders obj as JSON with the status code.
+func (c *Context) AbortWithStatusJSON(code int, obj interface{}) {
+	c.Abort()
+	c.JSON(code, obj)
+}
+
+// AbortWithError calls AbortWithStatus with the status code and collects err,
+// see Error. The error is not sent to the client.
+func (c *Context) AbortWithError(code int, err error) *Error {
+	c.AbortWithStatus(code)
+	return c.Error(err)
+}
+
+/************************************/
+/********* ERROR MANAGEMENT *********/
+/************************************/
+
+// Error collects err in c.Errors, so that middleware can handle or log the
+// errors of the request. err is added with ErrorTypePrivate unless it is an
+// *Error itself. The returned *Error allows to set its type and metadata.
+// It panics if err is nil.
+func (c *Context) Error(err error) *Error {
+	if err == nil {
+		panic("err is nil")
+	}
+
+	msg, ok := err.(*Error)
+	if !ok {
+		msg = &Error{
+			Err:  err,
+			Type: ErrorTypePrivate,
+		}
+	}
+	c.Errors = append(c.Errors, msg)
+	return msg
+}
+
+/************************************/
+/********* CONTEXT.CONTEXT **********/
+/************************************/
+
+var _ context.Context = &Context{}
+
+// hasRequestContext reports whether the context.Context methods delegate to
+// the request context, see Engine.ContextWithFallback.
+func (c *Context) hasRequestContext() bool {
+	return c.engineHasFallback() && c.Req != nil
+}
+
+// engineHasFallback reports whether the engine falls back to the request
+// context, see Engine.ContextWithFallback.
+func (c *Context) engineHasFallback() bool {
+	return c.engine != nil && c.engine.ContextWithFallback
+}
+
+// Deadline returns the deadline of the request context, if there is a
+// fallback to it. Otherwise there is no deadline.
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	if !c.hasRequestContext() {
+		return
+	}
+	return c.Req.Context().Deadline()
+}
+
+// closedChan is the Done channel of a released context.
+var closedChan = make(chan struct{})
+
+func init() {
+	close(closedChan)
+}
+
+// Done returns the Done channel of the request context, if there is a
+// fallback to it. Otherwise it returns nil, the context is never done.
+// A released context is done.
+func (c *Context) Done() <-chan struct{} {
+	if c.isReleased() {
+		return closedChan
+	}
+	if !c.hasRequestContext() {
+		return nil
+	}
+	return c.Req.Context().Done()
+}
+
+// Err returns the error of the request context, if there is a fallback to it.
+// Otherwise it returns nil. A released context is canceled.
+func (c *Context) Err() error {
+	if c.isReleased() {
+		return context.Canceled
+	}
+	if !c.hasRequestContext() {
+		return nil
+	}
+	return c.Req.Context().Err()
+}
+
+// Value returns the value of c.Keys for a string key. Other keys and missing
+// Keys are looked up in the request context, if there is a fallback to it.
+// Otherwise it returns nil, as it does for a released context.
+func (c *Context) Value(key interface{}) interface{} {
+	// it must not panic like Get, as the context may be held by any library
+	c.mu.RLock()
+	if c.isReleased() {
+		c.mu.RUnlock()
+		return nil
+	}
+	if keyAsString, ok := key.(string); ok {
+		if val, exists := c.Keys[keyAsString]; exists {
+			c.mu.RUnlock()
+			return val
+		}
+	}
+	c.mu.RUnlock()
+
+	if !c.engineHasFallback() {
+		return nil
+	}
+	if req := c.Req; req != nil {
+		return req.Context().Value(key)
+	}
+	return nil
+}
@@ -1,10 +1,17 @@
 package gee
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -29,3 +36,386 @@ func TestContextJSON(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, data, actual)
 }
+
+func TestContextTypedParams(t *testing.T) {
+	assert := assert.New(t)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	c := newContext(httptest.NewRecorder(), r)
+	c.AddParam("id", "42")
+	c.AddParam("big", "9223372036854775807")
+	c.AddParam("uuid", "0B6A3C6E-8F2A-4BD4-9A5E-2F3C1D6E7A8B")
+	c.AddParam("name", "gee")
+
+	id, err := c.ParamInt("id")
+	assert.Nil(err)
+	assert.Equal(42, id)
+
+	big, err := c.ParamInt64("big")
+	assert.Nil(err)
+	assert.Equal(int64(9223372036854775807), big)
+
+	uuid, err := c.ParamUUID("uuid")
+	assert.Nil(err)
+	assert.Equal("0b6a3c6e-8f2a-4bd4-9a5e-2f3c1d6e7a8b", uuid.String())
+
+	_, err = c.ParamInt("name")
+	assert.NotNil(err)
+	_, err = c.ParamInt64("missing")
+	assert.NotNil(err)
+	_, err = c.ParamUUID("name")
+	assert.Equal(errInvalidUUID, err)
+}
+
+func TestContextReset(t *testing.T) {
+	assert := assert.New(t)
+	r := httptest.NewRequest(http.MethodGet, "/a?q=1", nil)
+	c := newContext(httptest.NewRecorder(), r)
+	c.AddParam("id", "1")
+	c.Set("user", "gee")
+	c.Query("q")
+	c.fullPath = "/:id"
+	c.handlers = []HandlerFunc{func(c *Context) {}}
+	c.Next()
+	c.Status(http.StatusCreated)
+
+	c.writermem.reset(httptest.NewRecorder())
+	c.Req = httptest.NewRequest(http.MethodPost, "/b", nil)
+	c.reset()
+
+	assert.Equal("/b", c.Path)
+	assert.Equal(http.MethodPost, c.Method)
+	assert.Empty(c.Params)
+	assert.Equal("", c.FullPath())
+	assert.Nil(c.Keys)
+	assert.Nil(c.queryCache)
+	assert.Nil(c.handlers)
+	assert.Equal(-1, c.index)
+	assert.Equal(http.StatusOK, c.Writer.Status())
+	assert.False(c.Writer.Written())
+}
+
+func TestContextCopy(t *testing.T) {
+	assert := assert.New(t)
+	r := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	c := newContext(httptest.NewRecorder(), r)
+	c.AddParam("id", "1")
+	c.Set("user", "gee")
+	c.fullPath = "/users/:id"
+	c.Error(errors.New("cache miss"))
+
+	cp := c.Copy()
+	c.Params[0].Value = "2"
+	c.Set("user", "other")
+	c.Error(errors.New("late"))
+
+	assert.Equal("1", cp.Param("id"))
+	assert.Equal("gee", cp.GetString("user"))
+	assert.Equal("/users/:id", cp.FullPath())
+	assert.Equal(r, cp.Req)
+	assert.Nil(cp.handlers)
+	assert.Equal([]string{"cache miss"}, cp.Errors.Errors())
+}
+
+func TestContextClientIP(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	assert.Nil(e.SetTrustedProxies([]string{"10.0.0.0/8"}))
+
+	newRequest := func(remoteAddr string, header map[string]string) *Context {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		c := newContext(httptest.NewRecorder(), r)
+		c.engine = e
+		return c
+	}
+
+	tests := []struct {
+		remoteAddr string
+		header     map[string]string
+		ip         string
+	}{
+		{"1.2.3.4:1234", nil, "1.2.3.4"},
+		{"[2001:db8::1]:1234", nil, "2001:db8::1"},
+		// the headers of an untrusted remote are ignored
+		{"1.2.3.4:1234", map[string]string{"X-Forwarded-For": "5.6.7.8"}, "1.2.3.4"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "5.6.7.8"}, "5.6.7.8"},
+		// the first untrusted hop from the right is the client
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "9.9.9.9, 5.6.7.8, 10.0.0.2"}, "5.6.7.8"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		// an invalid X-Forwarded-For falls back to X-Real-IP
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "unknown", "X-Real-IP": "5.6.7.8"}, "5.6.7.8"},
+		{"10.0.0.1:1234", map[string]string{"X-Real-IP": "5.6.7.8"}, "5.6.7.8"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "bad"}, "10.0.0.1"},
+		{"invalid", nil, ""},
+	}
+	for _, tt := range tests {
+		c := newRequest(tt.remoteAddr, tt.header)
+		assert.Equal(tt.ip, c.ClientIP(), "%s %v", tt.remoteAddr, tt.header)
+	}
+
+	// the platform header is trusted for every request
+	e.TrustedPlatform = PlatformCloudflare
+	c := newRequest("1.2.3.4:1234", map[string]string{"CF-Connecting-IP": "5.6.7.8"})
+	assert.Equal("5.6.7.8", c.ClientIP())
+	c = newRequest("1.2.3.4:1234", nil)
+	assert.Equal("1.2.3.4", c.ClientIP())
+
+	e.TrustedPlatform = ""
+	e.RemoteIPHeaders = []string{"X-Real-IP"}
+	c = newRequest("10.0.0.1:1234", map[string]string{"X-Forwarded-For": "5.6.7.8", "X-Real-IP": "6.7.8.9"})
+	assert.Equal("6.7.8.9", c.ClientIP())
+	assert.Equal("10.0.0.1", c.RemoteIP())
+}
+
+func TestContextAbort(t *testing.T) {
+	assert := assert.New(t)
+	var trace []string
+	e := New()
+	e.Use(func(c *Context) {
+		trace = append(trace, "logger")
+		c.Next()
+		trace = append(trace, fmt.Sprintf("logger done %v", c.IsAborted()))
+	})
+	auth := e.Group("/admin")
+	auth.Use(func(c *Context) {
+		trace = append(trace, "auth")
+		if c.Query("token") == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, H{"error": "unauthorized"})
+		}
+		trace = append(trace, "auth done")
+	}, func(c *Context) {
+		trace = append(trace, "audit")
+	})
+	auth.GET("/users", func(c *Context) {
+		trace = append(trace, "handler")
+		c.String(http.StatusOK, "users")
+	})
+
+	w := performRequest(e, "GET", "/admin/users")
+	assert.Equal(http.StatusUnauthorized, w.Code)
+	assert.Equal(`{"error":"unauthorized"}`, w.Body.String())
+	assert.Equal([]string{"logger", "auth", "auth done", "logger done true"}, trace)
+
+	trace = nil
+	w = performRequest(e, "GET", "/admin/users?token=1")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal([]string{"logger", "auth", "auth done", "audit", "handler", "logger done false"}, trace)
+}
+
+func TestContextAbortWithStatus(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	e.GET("/status", func(c *Context) {
+		c.AbortWithStatus(http.StatusForbidden)
+	}, func(c *Context) {
+		c.String(http.StatusOK, "not reached")
+	})
+	errDenied := errors.New("denied")
+	e.GET("/error", func(c *Context) {
+		assert.Equal(errDenied, c.AbortWithError(http.StatusForbidden, errDenied).Err)
+		assert.True(c.IsAborted())
+	}, func(c *Context) {
+		c.String(http.StatusOK, "not reached")
+	})
+
+	for _, path := range []string{"/status", "/error"} {
+		w := performRequest(e, "GET", path)
+		assert.Equal(http.StatusForbidden, w.Code, path)
+		assert.Empty(w.Body.String(), path)
+	}
+}
+
+func TestContextError(t *testing.T) {
+	assert := assert.New(t)
+	c := newContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Empty(c.Errors)
+
+	errDB := errors.New("db: connection refused")
+	msg := c.Error(errDB)
+	assert.Equal(&Error{Err: errDB, Type: ErrorTypePrivate}, msg)
+	c.Error(errors.New("invalid id")).SetType(ErrorTypeBind | ErrorTypePublic).SetMeta(H{"field": "id"})
+	c.Error(&Error{Err: errors.New("not found"), Type: ErrorTypePublic})
+
+	assert.Equal(3, len(c.Errors))
+	assert.Equal("not found", c.Errors.Last().Error())
+	assert.Equal([]string{"db: connection refused"}, c.Errors.ByType(ErrorTypePrivate).Errors())
+	assert.Equal([]string{"invalid id", "not found"}, c.Errors.ByType(ErrorTypePublic).Errors())
+	assert.True(errors.Is(c.Errors[0], errDB))
+	assert.Panics(func() { c.Error(nil) })
+
+	// a context is reset without its errors
+	errs := c.Errors
+	c.reset()
+	assert.Nil(c.Errors)
+	c.Error(errors.New("next request"))
+	assert.Equal("db: connection refused", errs[0].Error())
+
+	// a failed binding is collected with ErrorTypeBind
+	c = newContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{")))
+	var obj struct{ ID int }
+	err := c.ShouldBindJSON(&obj)
+	assert.NotNil(err)
+	assert.Equal(Errors{{Err: err, Type: ErrorTypeBind}}, c.Errors)
+
+	// the type of an *Error is kept
+	public := &Error{Err: errors.New("invalid id"), Type: ErrorTypePublic}
+	c = newContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(public, c.shouldBindWith(&obj, errBinding{public}))
+	assert.Equal(ErrorTypePublic, public.Type)
+	assert.Equal(Errors{public}, c.Errors)
+}
+
+// errBinding is a binding failing with err.
+type errBinding struct {
+	err error
+}
+
+func (b errBinding) Name() string {
+	return "err"
+}
+
+func (b errBinding) Bind(*http.Request, interface{}) error {
+	return b.err
+}
+
+func TestContextFail(t *testing.T) {
+	assert := assert.New(t)
+	w := httptest.NewRecorder()
+	c := newContext(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	c.Fail(http.StatusInternalServerError, errors.New("db: password rejected"))
+	assert.Equal(http.StatusInternalServerError, w.Code)
+	assert.Equal("Internal Server Error", w.Body.String())
+	assert.Equal("db: password rejected", c.Errors.Last().Error())
+
+	w = httptest.NewRecorder()
+	c = newContext(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	c.Fail(http.StatusBadRequest, (&Error{Err: errors.New("name is required")}).SetType(ErrorTypePublic))
+	assert.Equal(http.StatusBadRequest, w.Code)
+	assert.Equal("name is required", w.Body.String())
+}
+
+type ctxKey struct{}
+
+func TestContextAsContext(t *testing.T) {
+	assert := assert.New(t)
+	deadline := time.Now().Add(time.Minute)
+	reqCtx, cancel := context.WithDeadline(context.WithValue(context.Background(), ctxKey{}, "request"), deadline)
+	defer cancel()
+	r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(reqCtx)
+
+	// without the fallback only the Keys are visible
+	c := newContext(httptest.NewRecorder(), r)
+	c.engine = New()
+	c.Set("user", "gee")
+	_, ok := c.Deadline()
+	assert.False(ok)
+	assert.Nil(c.Done())
+	assert.Nil(c.Err())
+	assert.Equal("gee", c.Value("user"))
+	assert.Nil(c.Value(ctxKey{}))
+
+	c.engine = New(WithContextFallback(true))
+	d, ok := c.Deadline()
+	assert.True(ok)
+	assert.Equal(deadline, d)
+	assert.Equal("gee", c.Value("user"))
+	assert.Equal("request", c.Value(ctxKey{}))
+	assert.Nil(c.Value("missing"))
+
+	var ctx context.Context = c
+	cancel()
+	<-ctx.Done()
+	assert.Equal(context.Canceled, ctx.Err())
+}
+
+func TestContextCookie(t *testing.T) {
+	assert := assert.New(t)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "user", Value: "gee%20web"})
+	c := newContext(w, r)
+
+	value, err := c.Cookie("user")
+	assert.Nil(err)
+	assert.Equal("gee web", value)
+	_, err = c.Cookie("missing")
+	assert.Equal(http.ErrNoCookie, err)
+
+	r.AddCookie(&http.Cookie{Name: "broken", Value: "gee%zz"})
+	_, err = c.Cookie("broken")
+	assert.Equal(url.EscapeError("%zz"), err)
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie("user", "gee web", 60, "", "example.com", true, true)
+	assert.Equal("user=gee+web; Path=/; Domain=example.com; Max-Age=60; HttpOnly; Secure; SameSite=Strict",
+		w.Header().Get("Set-Cookie"))
+}
+
+func TestContextSecureCookies(t *testing.T) {
+	assert := assert.New(t)
+	s, err := NewSecureCookie([][]byte{[]byte("hash key")}, [][]byte{bytes.Repeat([]byte{1}, 32)})
+	assert.Nil(err)
+	e := New(WithSecureCookie(s))
+	e.GET("/set", func(c *Context) {
+		assert.Nil(c.SetSignedCookie("signed", "user=42", 3600, "/", "", false, true))
+		assert.Nil(c.SetEncryptedCookie("encrypted", "user=42", 0, "/", "", false, true))
+	})
+	e.GET("/get", func(c *Context) {
+		signed, err := c.SignedCookie("signed")
+		if err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+		encrypted, err := c.EncryptedCookie("encrypted")
+		if err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+		c.String(http.StatusOK, "%s %s", signed, encrypted)
+	})
+
+	cookies := performRequest(e, "GET", "/set").Result().Cookies()
+	assert.Equal(2, len(cookies))
+
+	get := func(cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/get", nil)
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+		e.ServeHTTP(w, r)
+		return w
+	}
+	assert.Equal("user=42 user=42", get(cookies...).Body.String())
+
+	forged := *cookies[0]
+	forged.Value = tamper(forged.Value)
+	w := get(&forged, cookies[1])
+	assert.Equal(http.StatusUnauthorized, w.Code)
+	assert.Equal(ErrCookieTampered.Error(), w.Body.String())
+
+	// a value which does not unescape is taken for a tampered one
+	broken := *cookies[0]
+	broken.Value = "%zz"
+	w = get(&broken, cookies[1])
+	assert.Equal(ErrCookieTampered.Error(), w.Body.String())
+	broken = *cookies[1]
+	broken.Value = "%zz"
+	w = get(cookies[0], &broken)
+	assert.Equal(ErrCookieTampered.Error(), w.Body.String())
+
+	// the value of one cookie is not accepted for another
+	swapped := *cookies[1]
+	swapped.Value = cookies[0].Value
+	w = get(cookies[0], &swapped)
+	assert.Equal(ErrCookieTampered.Error(), w.Body.String())
+
+	c := newContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	c.engine = New()
+	assert.Equal(ErrNoSecureCookie, c.SetSignedCookie("signed", "v", 0, "", "", false, false))
+	_, err = c.EncryptedCookie("encrypted")
+	assert.Equal(ErrNoSecureCookie, err)
+}
@@ -2,16 +2,49 @@ package gee
 
 import (
 	"fmt"
-	"io"
-	"os"
+	"reflect"
+	"runtime"
 	"strings"
 )
 
-var DefaultWriter io.Writer = os.Stdout
-
+// debugPrint prints to DefaultWriter in DebugMode.
 func debugPrint(format string, values ...interface{}) {
+	if !IsDebugging() {
+		return
+	}
 	if !strings.HasPrefix(format, "\n") {
 		format += "\n"
 	}
 	fmt.Fprintf(DefaultWriter, "[GEE-debug] "+format, values...)
-}
\ No newline at end of file
+}
+
+// debugPrintWarning prints a warning to DefaultErrorWriter in DebugMode.
+func debugPrintWarning(format string, values ...interface{}) {
+	if !IsDebugging() {
+		return
+	}
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+	fmt.Fprintf(DefaultErrorWriter, "[GEE-debug] [WARNING] "+format, values...)
+}
+
+// debugPrintRoute prints a registered route with the name of its handler.
+func debugPrintRoute(method, path string, handlers []HandlerFunc) {
+	handlerName := nameOfFunction(lastHandler(handlers))
+	debugPrint("%-6s %-25s --> %s (%d handlers)", method, path, handlerName, len(handlers))
+}
+
+func lastHandler(handlers []HandlerFunc) HandlerFunc {
+	if len(handlers) == 0 {
+		return nil
+	}
+	return handlers[len(handlers)-1]
+}
+
+func nameOfFunction(f interface{}) string {
+	if f == nil || reflect.ValueOf(f).IsNil() {
+		return ""
+	}
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
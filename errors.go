@@ -0,0 +1,168 @@
+package gee
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ErrorType is the type of an Error, the types are bit flags.
+type ErrorType uint64
+
+const (
+	// ErrorTypeBind is used when binding the request failed, it is the type
+	// of the errors added by the ShouldBind methods of Context.
+	ErrorTypeBind ErrorType = 1 << 63
+	// ErrorTypeRender is used when rendering the response failed.
+	ErrorTypeRender ErrorType = 1 << 62
+	// ErrorTypePrivate is for errors which must not be shown to the client.
+	// It is the type of the errors added by Context.Error.
+	ErrorTypePrivate ErrorType = 1 << 0
+	// ErrorTypePublic is for errors whose message may be shown to the client.
+	ErrorTypePublic ErrorType = 1 << 1
+	// ErrorTypeAny matches every type in Errors.ByType.
+	ErrorTypeAny ErrorType = 1<<64 - 1
+)
+
+// Error is an error collected during a request, see Context.Error.
+type Error struct {
+	Err  error
+	Type ErrorType
+	Meta interface{}
+}
+
+// Errors are the errors collected during a request, in order.
+type Errors []*Error
+
+var _ error = &Error{}
+
+// Error returns the message of the wrapped error.
+func (msg *Error) Error() string {
+	return msg.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (msg *Error) Unwrap() error {
+	return msg.Err
+}
+
+// SetType sets the type of the error.
+func (msg *Error) SetType(flags ErrorType) *Error {
+	msg.Type = flags
+	return msg
+}
+
+// SetMeta sets the metadata of the error, like the field that failed to bind.
+func (msg *Error) SetMeta(data interface{}) *Error {
+	msg.Meta = data
+	return msg
+}
+
+// IsType reports whether the error has one of the types of flags.
+func (msg *Error) IsType(flags ErrorType) bool {
+	return (msg.Type & flags) > 0
+}
+
+// JSON returns a JSON serializable form of the error. The message is stored
+// as "error", a map of metadata is merged into the result, other metadata is
+// stored as "meta".
+func (msg *Error) JSON() interface{} {
+	jsonData := H{}
+	if msg.Meta != nil {
+		switch meta := msg.Meta.(type) {
+		case H:
+			for key, value := range meta {
+				jsonData[key] = value
+			}
+		case map[string]interface{}:
+			for key, value := range meta {
+				jsonData[key] = value
+			}
+		default:
+			jsonData["meta"] = msg.Meta
+		}
+	}
+	if _, ok := jsonData["error"]; !ok {
+		jsonData["error"] = msg.Error()
+	}
+	return jsonData
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (msg *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(msg.JSON())
+}
+
+// ByType returns the errors which have one of the types of flags.
+func (a Errors) ByType(flags ErrorType) Errors {
+	if len(a) == 0 {
+		return nil
+	}
+	if flags == ErrorTypeAny {
+		return a
+	}
+	var result Errors
+	for _, msg := range a {
+		if msg.IsType(flags) {
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+// Last returns the last error, nil if there is none.
+func (a Errors) Last() *Error {
+	if length := len(a); length > 0 {
+		return a[length-1]
+	}
+	return nil
+}
+
+// Errors returns the messages of the errors.
+func (a Errors) Errors() []string {
+	if len(a) == 0 {
+		return nil
+	}
+	messages := make([]string, len(a))
+	for i, msg := range a {
+		messages[i] = msg.Error()
+	}
+	return messages
+}
+
+// JSON returns a JSON serializable form of the errors, the form of the error
+// for a single one and a list otherwise.
+func (a Errors) JSON() interface{} {
+	switch length := len(a); length {
+	case 0:
+		return nil
+	case 1:
+		return a.Last().JSON()
+	default:
+		jsonData := make([]interface{}, length)
+		for i, msg := range a {
+			jsonData[i] = msg.JSON()
+		}
+		return jsonData
+	}
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (a Errors) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.JSON())
+}
+
+// String lists the errors, one per line.
+func (a Errors) String() string {
+	if len(a) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for i, msg := range a {
+		fmt.Fprintf(&sb, "Error #%02d: %s\n", i+1, msg.Err)
+		if msg.Meta != nil {
+			fmt.Fprintf(&sb, "     Meta: %v\n", msg.Meta)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,60 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorJSON(t *testing.T) {
+	assert := assert.New(t)
+	err := &Error{Err: errors.New("invalid id"), Type: ErrorTypeBind}
+	assert.Equal(H{"error": "invalid id"}, err.JSON())
+
+	err.SetMeta(H{"field": "id", "status": 400})
+	b, jsonErr := json.Marshal(err)
+	assert.Nil(jsonErr)
+	assert.Equal(`{"error":"invalid id","field":"id","status":400}`, string(b))
+
+	err.SetMeta("request 42")
+	assert.Equal(H{"error": "invalid id", "meta": "request 42"}, err.JSON())
+
+	// a message in the metadata is kept
+	err.SetMeta(H{"error": "id must be a number"})
+	assert.Equal(H{"error": "id must be a number"}, err.JSON())
+}
+
+func TestErrors(t *testing.T) {
+	assert := assert.New(t)
+	var errs Errors
+	assert.Nil(errs.Last())
+	assert.Nil(errs.ByType(ErrorTypeAny))
+	assert.Nil(errs.Errors())
+	assert.Nil(errs.JSON())
+	assert.Equal("", errs.String())
+
+	errs = append(errs,
+		&Error{Err: errors.New("bind failed"), Type: ErrorTypeBind},
+		&Error{Err: errors.New("render failed"), Type: ErrorTypeRender | ErrorTypePrivate},
+		&Error{Err: errors.New("not allowed"), Type: ErrorTypePublic, Meta: "admin"},
+	)
+	assert.Equal("not allowed", errs.Last().Error())
+	assert.Equal(errs, errs.ByType(ErrorTypeAny))
+	assert.Equal(Errors{errs[1]}, errs.ByType(ErrorTypePrivate))
+	assert.Equal(Errors{errs[0], errs[1]}, errs.ByType(ErrorTypeBind|ErrorTypeRender))
+	assert.Nil(errs.ByType(ErrorTypePrivate & ErrorTypePublic))
+
+	b, err := json.Marshal(errs)
+	assert.Nil(err)
+	assert.Equal(`[{"error":"bind failed"},{"error":"render failed"},{"error":"not allowed","meta":"admin"}]`, string(b))
+	b, err = json.Marshal(errs[:1])
+	assert.Nil(err)
+	assert.Equal(`{"error":"bind failed"}`, string(b))
+
+	assert.Equal("Error #01: bind failed\n"+
+		"Error #02: render failed\n"+
+		"Error #03: not allowed\n"+
+		"     Meta: admin\n", errs.String())
+}
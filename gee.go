@@ -1,38 +1,171 @@
 package gee
 
 import (
+	"context"
+	"fmt"
 	"html/template"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const defaultMultipartMemory = 32 << 20 // 32MB
 
 type HandlerFunc func(c *Context)
 
+// WrapF wraps an http.HandlerFunc into a gee handler.
+func WrapF(f http.HandlerFunc) HandlerFunc {
+	return func(c *Context) {
+		f(c.Writer, c.Req)
+	}
+}
+
+// WrapH wraps an http.Handler into a gee handler.
+func WrapH(h http.Handler) HandlerFunc {
+	return func(c *Context) {
+		h.ServeHTTP(c.Writer, c.Req)
+	}
+}
+
 type Engine struct {
 	*RouterGroup
-	router *router
-	groups []*RouterGroup // store all groups
+
+	// table holds the current *routeTable, mu serializes its updates and
+	// guards the registration state below.
+	table   atomic.Value
+	mu      sync.RWMutex
+	started bool
+
+	// the servers started by the Run functions and the hooks run by Shutdown
+	servers       []*http.Server
+	shutdownHooks []func(ctx context.Context) error
+
+	// pool reuses the contexts of finished requests
+	pool sync.Pool
+
+	// the patterns of the named routes, by name
+	namedRoutes map[string]string
+
+	// the NoRoute and NoMethod handlers, without the global middleware
+	noRoute  []HandlerFunc
+	noMethod []HandlerFunc
 
 	// for html render
 	htmlTemplates    *template.Template
 	funcMap          template.FuncMap
+	delims           [2]string // the template action delimiters, empty for the defaults
 	secureJSONPrefix string
 
+	// the networks of the proxies trusted to report the client IP,
+	// see SetTrustedProxies
+	trustedCIDRs []*net.IPNet
+
 	// Value of "maxMemory" param that is given to http.Request's ParseMultipartForm
 	// method call.
 	MaxMultipartMemory int64
+
+	// HandleMethodNotAllowed enables checking the routes of the other methods
+	// when the current request can not be routed. If one matches, the request
+	// is answered with 405 'Method Not Allowed' and an Allow header listing
+	// the methods of the path. OPTIONS requests for such a path are answered
+	// automatically, unless an OPTIONS route is registered for it.
+	HandleMethodNotAllowed bool
+
+	// RedirectTrailingSlash enables automatic redirection if the current route
+	// can not be matched but a handler for the path with (without) the
+	// trailing slash exists. For example if /foo/ is requested but a route
+	// only exists for /foo, the client is redirected to /foo with status 301
+	// for GET requests and 308 for all other request methods.
+	RedirectTrailingSlash bool
+
+	// RedirectFixedPath enables trying to fix the current request path if no
+	// handle is registered for it. Superfluous path elements like ../ or //
+	// are removed first, then a case-insensitive lookup of the cleaned path
+	// is made. If a route is found, the client is redirected to it with the
+	// same status codes as for RedirectTrailingSlash.
+	RedirectFixedPath bool
+
+	// RemoveExtraSlash routes requests with a cleaned path, so that params
+	// can be parsed from a URL with extra slashes, without any redirect.
+	RemoveExtraSlash bool
+
+	// UseRawPath routes with url.RawPath, the escaped form of the path, when
+	// it is set. A percent-encoded slash like in /files/a%2Fb then stays part
+	// of its segment instead of splitting it.
+	UseRawPath bool
+
+	// UnescapePathValues unescapes the param values when UseRawPath is set
+	// and the raw path was routed. It is enabled by default.
+	UnescapePathValues bool
+
+	// DynamicRouting allows to register and remove routes after the server
+	// started. Without it, changing the routes then panics, as it usually
+	// means that a route is registered too late. With it, each change copies
+	// the routes of its method, also before the start, since the engine may
+	// serve requests without a Run function, like behind an http.Server.
+	DynamicRouting bool
+
+	// RemoteIPHeaders are the headers Context.ClientIP reads the client IP
+	// from, in order, if the request comes from a trusted proxy. They are
+	// X-Forwarded-For and X-Real-IP by default.
+	RemoteIPHeaders []string
+
+	// TrustedPlatform is a header set by the platform the server runs on,
+	// like PlatformCloudflare, which holds the client IP. It is trusted for
+	// every request, so it must only be set when the platform overwrites
+	// the header sent by the client.
+	TrustedPlatform string
+
+	// ContextWithFallback makes the context.Context methods of Context
+	// delegate to the context of the request, so that its cancellation and
+	// values reach the libraries c is passed to. Without it Context is never
+	// done and Value only returns the Keys.
+	ContextWithFallback bool
+
+	// SecureCookie signs and encrypts the cookies of the SetSignedCookie and
+	// SetEncryptedCookie methods of Context.
+	SecureCookie *SecureCookie
+
+	// ServerConfig configures the http.Server of each Run function.
+	ServerConfig ServerConfig
+
+	// ShutdownTimeout limits how long Shutdown waits for the active requests
+	// to finish, in addition to the deadline of its context. Zero means no
+	// limit.
+	ShutdownTimeout time.Duration
 }
 
-func New() *Engine {
+// New returns a new blank Engine without any middleware, configured with the
+// given options.
+func New(opts ...OptionFunc) *Engine {
 	engine := &Engine{
-		router:             newRouter(),
-		secureJSONPrefix:   "while(1);",
-		MaxMultipartMemory: defaultMultipartMemory,
+		namedRoutes:           make(map[string]string),
+		secureJSONPrefix:      "while(1);",
+		MaxMultipartMemory:    defaultMultipartMemory,
+		RedirectTrailingSlash: true,
+		UnescapePathValues:    true,
+		RemoteIPHeaders:       []string{"X-Forwarded-For", "X-Real-IP"},
 	}
 	engine.RouterGroup = &RouterGroup{engine: engine}
-	engine.groups = []*RouterGroup{engine.RouterGroup}
+	engine.table.Store(&routeTable{router: newRouter()})
+	engine.pool.New = func() interface{} {
+		return engine.allocateContext()
+	}
+	for _, opt := range opts {
+		opt(engine)
+	}
+	return engine
+}
+
+// Default returns an Engine with the Logger and Recovery middleware attached,
+// configured with the given options.
+func Default(opts ...OptionFunc) *Engine {
+	engine := New(opts...)
+	engine.Use(Logger(), Recovery())
 	return engine
 }
 
@@ -41,42 +174,335 @@ func (e *Engine) SecureJsonPrefix(prefix string) *Engine {
 	return e
 }
 
-func (e *Engine) GET(pattern string, handler HandlerFunc) {
-	e.router.addRouter(http.MethodGet, pattern, handler)
+// Trusted platforms, see Engine.TrustedPlatform.
+const (
+	PlatformCloudflare      = "CF-Connecting-IP"
+	PlatformGoogleAppEngine = "X-Appengine-Remote-Addr"
+)
+
+// SetTrustedProxies sets the proxies which are trusted to report the client
+// IP in the RemoteIPHeaders, given as IPs or CIDRs like "10.0.0.0/8".
+// No proxy is trusted by default, nil restores that. If a proxy can not be
+// parsed, an error is returned and the trusted proxies stay unchanged.
+// It must not be called while requests are served.
+func (e *Engine) SetTrustedProxies(proxies []string) error {
+	cidrs := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return &net.ParseError{Type: "IP address", Text: proxy}
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			cidrs = append(cidrs, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return err
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	e.trustedCIDRs = cidrs
+	return nil
+}
+
+// isTrustedProxy reports whether ip belongs to a trusted proxy.
+func (e *Engine) isTrustedProxy(ip net.IP) bool {
+	for _, cidr := range e.trustedCIDRs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIPFromHeader returns the client IP of a header value, which lists the
+// IPs of the client and the proxies it passed. The IPs are checked from the
+// right, the first one that does not belong to a trusted proxy is the client.
+// It fails if an IP is invalid.
+func (e *Engine) clientIPFromHeader(header string) (string, bool) {
+	if header == "" {
+		return "", false
+	}
+	items := strings.Split(header, ",")
+	for i := len(items) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(items[i]))
+		if ip == nil {
+			return "", false
+		}
+		if i == 0 || !e.isTrustedProxy(ip) {
+			return ip.String(), true
+		}
+	}
+	return "", false
+}
+
+// Use attaches global middleware to the engine. It is included in the handler
+// chain of every route registered afterwards and of the NoRoute and NoMethod
+// handlers.
+func (e *Engine) Use(middleware ...HandlerFunc) {
+	e.updateTable(func(t *routeTable) {
+		e.RouterGroup.handlers = append(e.RouterGroup.handlers, middleware...)
+		t.global = e.combineHandlers(nil)
+		t.allNoRoute = e.combineHandlers(e.noRoute)
+		t.allNoMethod = e.combineHandlers(e.noMethod)
+	})
+}
+
+// NoRoute adds handlers for requests that match no route. They run after the
+// global middleware with the status preset to 404, if they write nothing the
+// default 404 message is sent.
+func (e *Engine) NoRoute(handlers ...HandlerFunc) {
+	e.updateTable(func(t *routeTable) {
+		e.noRoute = handlers
+		t.allNoRoute = e.combineHandlers(handlers)
+	})
 }
 
-func (e *Engine) PUT(pattern string, handler HandlerFunc) {
-	e.router.addRouter(http.MethodPut, pattern, handler)
+// NoMethod sets the handlers called when HandleMethodNotAllowed is enabled and
+// the path only matches routes of other methods. They run after the global
+// middleware with the status preset to 405 and the Allow header set.
+func (e *Engine) NoMethod(handlers ...HandlerFunc) {
+	e.updateTable(func(t *routeTable) {
+		e.noMethod = handlers
+		t.allNoMethod = e.combineHandlers(handlers)
+	})
 }
 
-func (e *Engine) POST(pattern string, handler HandlerFunc) {
-	e.router.addRouter(http.MethodPost, pattern, handler)
+func (e *Engine) allocateContext() *Context {
+	return &Context{
+		engine: e,
+		Params: make(Params, 0, e.loadTable().maxParams()),
+	}
 }
 
 func (e *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	// combine handlers by group prefix
-	var middlewares []HandlerFunc
-	for _, group := range e.groups {
-		if strings.HasPrefix(req.URL.Path, group.prefix) {
-			middlewares = append(middlewares, group.handlers...)
-		}
+	c := e.pool.Get().(*Context)
+	c.writermem.reset(w)
+	c.Req = req
+	c.reset()
+
+	e.handleHTTPRequest(c)
+	c.Writer.WriteHeaderNow()
+
+	c.release()
+	e.pool.Put(c)
+}
+
+// maxHandleContextDepth limits the nesting of HandleContext calls,
+// deeper calls are taken for a rewrite loop.
+const maxHandleContextDepth = 10
+
+// HandleContext routes c again, usually after a handler rewrote
+// c.Req.URL.Path, without redirecting the client. The handler chain of the
+// new route runs with the same Keys and response writer. When it is done,
+// the rest of the original chain continues, unless the new chain aborted.
+// If the path is routed with UseRawPath, c.Req.URL.RawPath has to be
+// rewritten or cleared as well. Rewrite loops are answered with 508 'Loop Detected'.
+func (e *Engine) HandleContext(c *Context) {
+	c.mustBeActive()
+	if c.depth >= maxHandleContextDepth {
+		c.String(http.StatusLoopDetected, "508 LOOP DETECTED: %s %s \n", c.Method, c.Path)
+		c.Abort()
+		return
+	}
+
+	handlers, index := c.handlers, c.index
+	c.depth++
+	c.Path = c.Req.URL.Path
+	c.Method = c.Req.Method
+	c.Params = c.Params[:0]
+	c.fullPath = ""
+	c.handlers = nil
+	c.index = -1
+	c.queryCache = nil
+
+	e.handleHTTPRequest(c)
+
+	c.depth--
+	c.handlers = handlers
+	if !c.IsAborted() {
+		c.index = index
 	}
-	c := newContext(w, req)
-	c.handlers = middlewares
-	c.engine = e
-	e.router.handle(c)
 }
 
-// start server
-func (e *Engine) Run(addr string) (err error) {
-	return http.ListenAndServe(addr, e)
+func (e *Engine) handleHTTPRequest(c *Context) {
+	rPath := c.Path
+	unescape := false
+	if e.UseRawPath && c.Req.URL.RawPath != "" {
+		rPath = c.Req.URL.RawPath
+		unescape = e.UnescapePathValues
+	}
+	if e.RemoveExtraSlash {
+		rPath = cleanPath(rPath)
+	}
+
+	// the request is routed with one snapshot of the routes, even if they
+	// change meanwhile
+	t := e.loadTable()
+	params := c.Params[:0]
+	if max := t.maxParams(); cap(params) < max {
+		// the routes changed since the context was allocated
+		params = make(Params, 0, max)
+	}
+
+	// the routes of a matching host come first,
+	// the host-agnostic routes are the fallback.
+	var hostRouter *router
+	if h := t.matchHost(c.Req.Host, &params); h != nil {
+		hostRouter = h.router
+	}
+	routers := [...]*router{hostRouter, t.router}
+
+	for i, r := range routers {
+		if r == nil {
+			continue
+		}
+		if i > 0 {
+			// the host params only belong to the host routes
+			params = params[:0]
+		}
+		if n, fromGet := r.lookup(c.Method, rPath, &params); n != nil {
+			if fromGet {
+				// serve HEAD from the GET route, without the body
+				c.Writer = &headResponseWriter{ResponseWriter: c.Writer}
+			}
+			if unescape {
+				for i := range params {
+					if value, err := url.PathUnescape(params[i].Value); err == nil {
+						params[i].Value = value
+					}
+				}
+			}
+			c.Params = params
+			c.fullPath = n.pattern
+			c.handlers = n.handlers
+			c.Next()
+			return
+		}
+	}
+
+	for _, r := range routers {
+		if r == nil {
+			continue
+		}
+		if location, ok := r.redirectPath(e, c.Method, rPath, &params); ok {
+			redirectRequest(c, location)
+			return
+		}
+		if c.Method == http.MethodHead {
+			if location, ok := r.redirectPath(e, http.MethodGet, rPath, &params); ok {
+				redirectRequest(c, location)
+				return
+			}
+		}
+	}
+
+	if e.HandleMethodNotAllowed {
+		// both routers serve the path, so the methods of both are allowed
+		var methods []string
+		for _, r := range routers {
+			if r != nil {
+				methods = r.allowedMethods(rPath, &params, methods)
+			}
+		}
+		if allow := allowHeader(methods); allow != "" {
+			c.Writer.Header().Set("Allow", allow)
+			if c.Method == http.MethodOptions {
+				// only the global middleware runs, e.g. to add CORS headers
+				c.handlers = t.global
+				c.Status(http.StatusNoContent)
+				c.Next()
+				return
+			}
+			c.handlers = t.allNoMethod
+			serveError(c, http.StatusMethodNotAllowed, "405 METHOD NOT ALLOWED: %s %s \n")
+			return
+		}
+	}
+	c.handlers = t.allNoRoute
+	serveError(c, http.StatusNotFound, "404 NOT FOUND: %s %s \n")
 }
 
 func (e *Engine) SetFuncMap(funcMap template.FuncMap) {
 	e.funcMap = funcMap
 }
 
+// Routes returns the registered routes, the host-agnostic routes come first,
+// then the routes of each host in the order of the Host calls. Each set is
+// sorted by path and method.
+func (e *Engine) Routes() []RouteInfo {
+	t := e.loadTable()
+	routes := t.router.routes()
+	for _, h := range t.hosts {
+		for _, route := range h.router.routes() {
+			route.Host = h.pattern
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// URLFor builds the path of the route with the given name, its wildcards are
+// replaced with params in order. It fails if no route has the name or the
+// number of params does not match the wildcards of the route.
+// The templates loaded with LoadHTMLGlob can call it as "urlfor".
+func (e *Engine) URLFor(name string, params ...interface{}) (string, error) {
+	e.mu.RLock()
+	pattern, ok := e.namedRoutes[name]
+	e.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("no route named %q", name)
+	}
+
+	var sb strings.Builder
+	path := pattern
+	for {
+		i := wildcardIndex(path)
+		if i < 0 {
+			sb.WriteString(path)
+			break
+		}
+		sb.WriteString(path[:i])
+
+		end := wildcardEnd(path, i)
+		wildcard := path[i:end]
+		if len(params) == 0 {
+			return "", fmt.Errorf("missing param %q for route %q", wildcard, name)
+		}
+
+		value := fmt.Sprint(params[0])
+		if wildcard[0] == '*' {
+			// a catch-all value may span segments
+			segments := strings.Split(value, "/")
+			for j := range segments {
+				segments[j] = url.PathEscape(segments[j])
+			}
+			value = strings.Join(segments, "/")
+		} else {
+			value = url.PathEscape(value)
+		}
+		sb.WriteString(value)
+
+		params = params[1:]
+		path = path[end:]
+	}
+
+	if len(params) > 0 {
+		return "", fmt.Errorf("too many params for route %q", name)
+	}
+	return sb.String(), nil
+}
+
 func (e *Engine) LoadHTMLGlob(pattern string) {
+	funcMap := template.FuncMap{"urlfor": e.URLFor}
+	for name, fn := range e.funcMap {
+		funcMap[name] = fn
+	}
 	e.htmlTemplates = template.Must(
-		template.New("").Funcs(e.funcMap).ParseGlob(pattern))
+		template.New("").Delims(e.delims[0], e.delims[1]).Funcs(funcMap).ParseGlob(pattern))
 }
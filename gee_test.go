@@ -0,0 +1,472 @@
+package gee
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func performRequest(e *Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestEngineMethods(t *testing.T) {
+	e := New()
+	handler := func(c *Context) {
+		c.String(http.StatusOK, c.Method)
+	}
+	e.GET("/get", handler)
+	e.POST("/post", handler)
+	e.PUT("/put", handler)
+	e.PATCH("/patch", handler)
+	e.DELETE("/delete", handler)
+	e.OPTIONS("/options", handler)
+	e.HEAD("/head", handler)
+	e.CONNECT("/connect", handler)
+	e.TRACE("/trace", handler)
+	e.Handle("PROPFIND", "/propfind", handler)
+
+	v1 := e.Group("/v1")
+	v1.Any("/any", handler)
+
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE", "PROPFIND"} {
+		w := performRequest(e, method, "/"+strings.ToLower(method))
+		assert.Equal(t, http.StatusOK, w.Code, method)
+		assert.Equal(t, method, w.Body.String())
+	}
+	for _, method := range anyMethods {
+		w := performRequest(e, method, "/v1/any")
+		assert.Equal(t, http.StatusOK, w.Code, method)
+	}
+
+	w := performRequest(e, http.MethodPost, "/get")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	assert.Panics(t, func() { e.Handle("", "/empty", handler) })
+}
+
+func TestEngineHeadFromGet(t *testing.T) {
+	e := New()
+	e.GET("/users", func(c *Context) {
+		c.JSON(http.StatusOK, H{"name": "gee"})
+	})
+	e.GET("/explicit", func(c *Context) {
+		c.String(http.StatusOK, "get")
+	})
+	e.HEAD("/explicit", func(c *Context) {
+		c.Writer.Header().Set("X-Head", "1")
+		c.Status(http.StatusNoContent)
+	})
+
+	w := performRequest(e, http.MethodHead, "/users")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Body.String())
+
+	w = performRequest(e, http.MethodHead, "/explicit")
+	assert.Equal(t, "1", w.Header().Get("X-Head"))
+
+	w = performRequest(e, http.MethodHead, "/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestEngineNoRoute(t *testing.T) {
+	var trace []string
+	e := New()
+	e.NoRoute(func(c *Context) {
+		trace = append(trace, "noroute")
+		c.JSON(http.StatusNotFound, H{"error": "not found", "path": c.Path})
+	})
+	// global middleware registered after NoRoute still runs first
+	e.Use(func(c *Context) {
+		trace = append(trace, "global")
+		c.Next()
+	})
+	e.Group("/v1").Use(func(c *Context) {
+		trace = append(trace, "v1")
+	})
+
+	w := performRequest(e, http.MethodGet, "/v1/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error":"not found","path":"/v1/missing"}`, w.Body.String())
+	assert.Equal(t, []string{"global", "noroute"}, trace)
+}
+
+func TestEngineNoMethod(t *testing.T) {
+	e := New()
+	e.HandleMethodNotAllowed = true
+	e.GET("/users", func(c *Context) {})
+
+	// the default message is kept when the handlers write nothing
+	called := false
+	e.NoMethod(func(c *Context) {
+		called = true
+	})
+	w := performRequest(e, http.MethodPost, "/users")
+	assert.True(t, called)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "405 METHOD NOT ALLOWED: POST /users \n", w.Body.String())
+
+	e.NoMethod(func(c *Context) {
+		c.String(http.StatusMethodNotAllowed, "allow: %s", c.Writer.Header().Get("Allow"))
+	})
+	w = performRequest(e, http.MethodPost, "/users")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "allow: GET, HEAD, OPTIONS", w.Body.String())
+
+	w = performRequest(e, http.MethodPost, "/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "404 NOT FOUND: POST /missing \n", w.Body.String())
+}
+
+func TestEngineURLFor(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	users := e.Group("/v1/users")
+	users.GET("/:id", func(c *Context) {}).Name("user.show")
+	users.GET("/:id/posts/:post", func(c *Context) {}).Name("user.post")
+	e.GET("/files/*path", func(c *Context) {}).Name("files")
+	e.GET("/about", func(c *Context) {}).Name("about")
+
+	url, err := e.URLFor("user.show", 10)
+	assert.Nil(err)
+	assert.Equal("/v1/users/10", url)
+
+	url, err = e.URLFor("user.post", "gee", "a b/c")
+	assert.Nil(err)
+	assert.Equal("/v1/users/gee/posts/a%20b%2Fc", url)
+
+	url, err = e.URLFor("files", "css/main app.css")
+	assert.Nil(err)
+	assert.Equal("/files/css/main%20app.css", url)
+
+	url, err = e.URLFor("about")
+	assert.Nil(err)
+	assert.Equal("/about", url)
+
+	_, err = e.URLFor("user.post", "gee")
+	assert.EqualError(err, `missing param ":post" for route "user.post"`)
+	_, err = e.URLFor("about", 1)
+	assert.EqualError(err, `too many params for route "about"`)
+	_, err = e.URLFor("missing")
+	assert.EqualError(err, `no route named "missing"`)
+
+	assert.Panics(func() {
+		e.GET("/v2/users/:id", func(c *Context) {}).Name("user.show")
+	})
+}
+
+func TestEngineURLForTemplate(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "user.tmpl"),
+		[]byte(`{{ define "user" }}<a href="{{ urlfor "user.show" .ID }}">{{ .Name }}</a>{{ end }}`), 0644)
+	assert.Nil(t, err)
+
+	e := New()
+	e.LoadHTMLGlob(filepath.Join(dir, "*"))
+	e.GET("/users/:id", func(c *Context) {
+		c.HTML(http.StatusOK, "user", H{"ID": c.Param("id"), "Name": "gee"})
+	}).Name("user.show")
+
+	w := performRequest(e, http.MethodGet, "/users/7")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `<a href="/users/7">gee</a>`, w.Body.String())
+}
+
+func handlerTest1(c *Context) {}
+func handlerTest2(c *Context) {}
+
+func TestEngineRoutes(t *testing.T) {
+	e := New()
+	e.Use(func(c *Context) {})
+	e.GET("/users/:id", handlerTest1)
+	e.POST("/users", func(c *Context) {}, handlerTest2)
+	e.Group("/v1").DELETE("/files/*path", handlerTest1)
+
+	routes := e.Routes()
+	assert.Len(t, routes, 3)
+	assert.Equal(t, RouteInfo{Method: "POST", Path: "/users", Handler: "gee.handlerTest2", NumHandlers: 3},
+		withoutFunc(routes[0]))
+	assert.Equal(t, RouteInfo{Method: "GET", Path: "/users/:id", Handler: "gee.handlerTest1", NumHandlers: 2},
+		withoutFunc(routes[1]))
+	assert.Equal(t, RouteInfo{Method: "DELETE", Path: "/v1/files/*path", Handler: "gee.handlerTest1", NumHandlers: 2},
+		withoutFunc(routes[2]))
+	assert.NotNil(t, routes[0].HandlerFunc)
+}
+
+func withoutFunc(info RouteInfo) RouteInfo {
+	info.HandlerFunc = nil
+	return info
+}
+
+func TestDebugPrintRoute(t *testing.T) {
+	out, _ := captureOutput(DebugMode, func() {
+		e := New()
+		e.GET("/users/:id", handlerTest1)
+	})
+	assert.Equal(t, "[GEE-debug] GET    /users/:id                --> gee.handlerTest1 (1 handlers)\n", out)
+}
+
+func TestEngineFullPathAndParams(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	e.GET("/repos/:owner/:repo/*path", func(c *Context) {
+		assert.Equal("/repos/:owner/:repo/*path", c.FullPath())
+		assert.Equal(Params{
+			{Key: "owner", Value: "gee"},
+			{Key: "repo", Value: "web"},
+			{Key: "path", Value: "docs/README.md"},
+		}, c.Params)
+
+		value, ok := c.Params.Get("repo")
+		assert.True(ok)
+		assert.Equal("web", value)
+		_, ok = c.Params.Get("missing")
+		assert.False(ok)
+	})
+	e.NoRoute(func(c *Context) {
+		assert.Equal("", c.FullPath())
+	})
+
+	performRequest(e, http.MethodGet, "/repos/gee/web/docs/README.md")
+	performRequest(e, http.MethodGet, "/missing")
+}
+
+func TestEngineUseRawPath(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	e.GET("/files/:name", func(c *Context) {
+		c.String(http.StatusOK, c.Param("name"))
+	})
+
+	// the escaped slash splits the segment by default
+	w := performRequest(e, http.MethodGet, "/files/a%2Fb")
+	assert.Equal(http.StatusNotFound, w.Code)
+
+	e.UseRawPath = true
+	w = performRequest(e, http.MethodGet, "/files/a%2Fb")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("a/b", w.Body.String())
+
+	e.UnescapePathValues = false
+	w = performRequest(e, http.MethodGet, "/files/a%2Fb")
+	assert.Equal("a%2Fb", w.Body.String())
+}
+
+// benchWriter is a ResponseWriter that allocates nothing itself.
+type benchWriter struct {
+	header http.Header
+}
+
+func (w *benchWriter) Header() http.Header         { return w.header }
+func (w *benchWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *benchWriter) WriteHeader(code int)        {}
+
+func benchmarkServe(b *testing.B, e *Engine, method, path string) {
+	req := httptest.NewRequest(method, path, nil)
+	w := &benchWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.ServeHTTP(w, req)
+	}
+}
+
+func newBenchEngine() *Engine {
+	e := New()
+	for _, route := range githubAPI {
+		e.Handle(route.method, route.path, func(c *Context) {})
+	}
+	return e
+}
+
+func BenchmarkServeStatic(b *testing.B) {
+	benchmarkServe(b, newBenchEngine(), "GET", "/user/repos")
+}
+
+func BenchmarkServeParam(b *testing.B) {
+	benchmarkServe(b, newBenchEngine(), "GET", "/repos/julienschmidt/httprouter/stargazers")
+}
+
+func TestEngineContextReuse(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	e.GET("/set/:id", func(c *Context) {
+		c.Set("seen", true)
+		c.String(http.StatusOK, c.Param("id"))
+	})
+	e.GET("/get", func(c *Context) {
+		_, seen := c.Get("seen")
+		c.String(http.StatusOK, "%v %d", seen, len(c.Params))
+	})
+
+	// the pooled contexts keep nothing from the earlier requests
+	for i := 0; i < 10; i++ {
+		assert.Equal("1", performRequest(e, "GET", "/set/1").Body.String())
+		assert.Equal("false 0", performRequest(e, "GET", "/get").Body.String())
+	}
+}
+
+func TestEngineContextEscaped(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	var escaped, copied *Context
+	e.GET("/users/:id", func(c *Context) {
+		c.Set("user", "gee")
+		escaped, copied = c, c.Copy()
+	})
+	performRequest(e, "GET", "/users/1")
+
+	// the use of the context after the handler returned is caught
+	assert.PanicsWithValue(errContextReleased, func() { escaped.Param("id") })
+	assert.PanicsWithValue(errContextReleased, func() { escaped.FullPath() })
+	assert.Equal("", escaped.Path)
+	assert.Equal("", escaped.Method)
+	assert.PanicsWithValue(errContextReleased, func() { escaped.Get("user") })
+	assert.PanicsWithValue(errContextReleased, func() { escaped.Set("user", "other") })
+	assert.PanicsWithValue(errContextReleased, func() { escaped.Copy() })
+	assert.PanicsWithValue(errContextReleased, func() { escaped.String(http.StatusOK, "late") })
+	assert.PanicsWithValue(errContextReleased, func() { e.HandleContext(escaped) })
+	assert.Nil(escaped.Req)
+	assert.Equal(context.Canceled, escaped.Err())
+	assert.Nil(escaped.Value("user"))
+	select {
+	case <-escaped.Done():
+	default:
+		t.Error("released context is not done")
+	}
+
+	// the copy stays usable
+	assert.Equal("1", copied.Param("id"))
+	assert.Equal("gee", copied.MustGet("user"))
+	assert.Nil(copied.Err())
+}
+
+func TestNewOptions(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "page.tmpl"),
+		[]byte(`<% define "page" %><% .Title %> {{ raw }}<% end %>`), 0644)
+	assert.Nil(err)
+
+	e := New(
+		WithSecureJSONPrefix(")]}',\n"),
+		WithMaxMultipartMemory(1<<10),
+		WithRedirectTrailingSlash(false),
+		WithRedirectFixedPath(true),
+		WithTrustedProxies([]string{"10.0.0.0/8"}),
+		WithDelims("<%", "%>"),
+	)
+	assert.Equal(int64(1<<10), e.MaxMultipartMemory)
+	assert.False(e.RedirectTrailingSlash)
+	assert.True(e.RedirectFixedPath)
+	assert.Equal("10.0.0.0/8", e.trustedCIDRs[0].String())
+
+	e.LoadHTMLGlob(filepath.Join(dir, "*"))
+	e.GET("/page", func(c *Context) {
+		c.HTML(http.StatusOK, "page", H{"Title": "gee"})
+	})
+	e.GET("/json", func(c *Context) {
+		c.SecureJSON(http.StatusOK, []int{1})
+	})
+	assert.Equal("gee {{ raw }}", performRequest(e, "GET", "/page").Body.String())
+	assert.Equal(")]}',\n[1]", performRequest(e, "GET", "/json").Body.String())
+	assert.Equal(http.StatusMovedPermanently, performRequest(e, "GET", "/PAGE").Code)
+	assert.Equal(http.StatusNotFound, performRequest(e, "GET", "/page/").Code)
+
+	// the defaults
+	e = New()
+	assert.Equal(int64(defaultMultipartMemory), e.MaxMultipartMemory)
+	assert.True(e.RedirectTrailingSlash)
+	assert.False(e.RedirectFixedPath)
+}
+
+func TestDefault(t *testing.T) {
+	e := Default(WithRedirectTrailingSlash(false))
+	assert.False(t, e.RedirectTrailingSlash)
+	assert.Equal(t, 2, len(e.RouterGroup.handlers))
+
+	e.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+	var w *httptest.ResponseRecorder
+	captureOutput(TestMode, func() {
+		w = performRequest(e, "GET", "/panic")
+	})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestEngineSetTrustedProxies(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	assert.Nil(e.SetTrustedProxies([]string{"10.0.0.1", "192.168.0.0/16", "::1", "fd00::/8"}))
+	for _, ip := range []string{"10.0.0.1", "192.168.1.2", "::1", "fd12::1"} {
+		assert.True(e.isTrustedProxy(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{"10.0.0.2", "172.16.0.1", "::2"} {
+		assert.False(e.isTrustedProxy(net.ParseIP(ip)), ip)
+	}
+
+	// an invalid proxy keeps the trusted ones
+	assert.NotNil(e.SetTrustedProxies([]string{"10.0.0.300"}))
+	assert.NotNil(e.SetTrustedProxies([]string{"10.0.0.0/33"}))
+	assert.True(e.isTrustedProxy(net.ParseIP("10.0.0.1")))
+	assert.Panics(func() { New(WithTrustedProxies([]string{"proxy"})) })
+
+	assert.Nil(e.SetTrustedProxies(nil))
+	assert.False(e.isTrustedProxy(net.ParseIP("10.0.0.1")))
+}
+
+func TestEngineHandleContext(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	var trace []string
+	e.Use(func(c *Context) {
+		trace = append(trace, "global "+c.Path)
+		c.Next()
+		trace = append(trace, "global done "+c.Path)
+	})
+	e.GET("/legacy/:name", func(c *Context) {
+		c.Set("rewritten", true)
+		c.Req.URL.Path = "/v2/" + c.Param("name")
+		e.HandleContext(c)
+		trace = append(trace, "legacy done")
+	})
+	e.GET("/v2/:name", func(c *Context) {
+		c.String(http.StatusOK, "v2 %s %v %s", c.Param("name"), c.GetBool("rewritten"), c.FullPath())
+	})
+	e.GET("/loop", func(c *Context) {
+		c.Req.URL.Path = "/loop"
+		e.HandleContext(c)
+	})
+
+	w := performRequest(e, "GET", "/legacy/x")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("v2 x true /v2/:name", w.Body.String())
+	assert.Equal([]string{
+		"global /legacy/x",
+		"global /v2/x",
+		"global done /v2/x",
+		"legacy done",
+		"global done /v2/x",
+	}, trace)
+
+	w = performRequest(e, "GET", "/loop")
+	assert.Equal(http.StatusLoopDetected, w.Code)
+	assert.Equal("508 LOOP DETECTED: GET /loop \n", w.Body.String())
+
+	// the depth is not kept by the pooled contexts
+	w = performRequest(e, "GET", "/legacy/y")
+	assert.Equal("v2 y true /v2/:name", w.Body.String())
+}
@@ -0,0 +1,116 @@
+package gee
+
+import (
+	"net"
+	"strings"
+)
+
+// hostRouter holds the routes registered for a host pattern.
+type hostRouter struct {
+	pattern string
+	labels  []string
+	keys    []string // the names of the param labels
+	router  *router
+}
+
+// Host returns a group for the routes which only serve requests to the host
+// pattern. A label of the pattern may be a param like ":tenant.example.com",
+// its value is available through c.Param. Labels are compared without case.
+// Requests to other hosts, or which match none of the host's routes, are
+// served by the host-agnostic routes of the engine. The global middleware of
+// the engine applies to the host routes as well. The port of a request is
+// ignored, a pattern must not have one.
+func (e *Engine) Host(pattern string) *RouterGroup {
+	pattern = strings.ToLower(pattern)
+
+	e.updateTable(func(t *routeTable) {
+		for _, h := range t.hosts {
+			if h.pattern == pattern {
+				return
+			}
+		}
+		hosts := make([]*hostRouter, len(t.hosts), len(t.hosts)+1)
+		copy(hosts, t.hosts)
+		t.hosts = append(hosts, newHostRouter(pattern))
+	})
+
+	return &RouterGroup{
+		parent: e.RouterGroup,
+		engine: e,
+		host:   pattern,
+	}
+}
+
+func newHostRouter(pattern string) *hostRouter {
+	h := &hostRouter{
+		pattern: pattern,
+		labels:  strings.Split(pattern, "."),
+		router:  newRouter(),
+	}
+	for _, label := range h.labels {
+		if label == "" || label == ":" {
+			panic("host pattern '" + pattern + "' has an empty label")
+		}
+		if strings.IndexByte(label[1:], ':') >= 0 {
+			panic("host pattern '" + pattern + "' must not have a port")
+		}
+		if label[0] == ':' {
+			h.keys = append(h.keys, label[1:])
+		}
+	}
+	return h
+}
+
+// matchHost returns the host router for the host of a request
+// and appends its params, nil if no host pattern matches.
+func (t *routeTable) matchHost(host string, params *Params) *hostRouter {
+	if len(t.hosts) == 0 {
+		return nil
+	}
+
+	host = stripPort(host)
+	for _, h := range t.hosts {
+		if h.match(host, params) {
+			return h
+		}
+	}
+	return nil
+}
+
+// stripPort returns host without its port and without the brackets of an
+// IPv6 address.
+func stripPort(host string) string {
+	if strings.LastIndexByte(host, ':') > strings.LastIndexByte(host, ']') {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			return h
+		}
+		// a bare IPv6 address
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
+
+// match reports whether host matches the pattern of h, the values of the
+// param labels are appended to params.
+func (h *hostRouter) match(host string, params *Params) bool {
+	i := len(*params)
+	for k, label := range h.labels {
+		part := host
+		j := strings.IndexByte(host, '.')
+		if last := k == len(h.labels)-1; last != (j < 0) {
+			// the host has more or fewer labels than the pattern
+			*params = (*params)[:i]
+			return false
+		} else if !last {
+			part, host = host[:j], host[j+1:]
+		}
+
+		if label[0] == ':' && part != "" {
+			*params = append(*params, Param{Key: label[1:], Value: part})
+		} else if !strings.EqualFold(label, part) {
+			*params = (*params)[:i]
+			return false
+		}
+	}
+	return true
+}
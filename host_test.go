@@ -0,0 +1,109 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func performHostRequest(e *Engine, method, host, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	req.Host = host
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestEngineHost(t *testing.T) {
+	assert := assert.New(t)
+	var trace []string
+	e := New()
+	e.Use(func(c *Context) {
+		trace = append(trace, "global")
+	})
+
+	api := e.Host("api.example.com")
+	api.GET("/users", func(c *Context) {
+		c.String(http.StatusOK, "api users")
+	})
+	tenant := e.Host(":tenant.example.com").Group("/v1")
+	tenant.GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "%s %s", c.Param("tenant"), c.Param("id"))
+	})
+	e.GET("/users", func(c *Context) {
+		c.String(http.StatusOK, "users")
+	})
+	e.GET("/health", func(c *Context) {
+		c.String(http.StatusOK, "ok %q", c.Param("tenant"))
+	})
+
+	w := performHostRequest(e, http.MethodGet, "API.example.com:8080", "/users")
+	assert.Equal("api users", w.Body.String())
+	assert.Equal([]string{"global"}, trace)
+
+	w = performHostRequest(e, http.MethodGet, "acme.example.com", "/v1/users/7")
+	assert.Equal("acme 7", w.Body.String())
+
+	// the host-agnostic routes are the fallback
+	w = performHostRequest(e, http.MethodGet, "www.example.org", "/users")
+	assert.Equal("users", w.Body.String())
+	w = performHostRequest(e, http.MethodGet, "acme.example.com", "/health")
+	assert.Equal(`ok ""`, w.Body.String())
+
+	w = performHostRequest(e, http.MethodGet, "a.b.example.com", "/v1/users/7")
+	assert.Equal(http.StatusNotFound, w.Code)
+	w = performHostRequest(e, http.MethodGet, "example.com", "/v1/users/7")
+	assert.Equal(http.StatusNotFound, w.Code)
+
+	routes := e.Routes()
+	assert.Len(routes, 4)
+	assert.Equal("", routes[0].Host)
+	assert.Equal("api.example.com", routes[2].Host)
+	assert.Equal(":tenant.example.com", routes[3].Host)
+	assert.Equal("/v1/users/:id", routes[3].Path)
+
+	assert.Panics(func() { e.Host("api..example.com") })
+	assert.PanicsWithValue("host pattern 'api.example.com:8080' must not have a port", func() {
+		e.Host("api.example.com:8080")
+	})
+}
+
+func TestEngineHostMethodNotAllowed(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	e.HandleMethodNotAllowed = true
+	api := e.Host(":tenant.example.com")
+	api.GET("/users/:id", func(c *Context) {})
+	api.DELETE("/users/:id", func(c *Context) {})
+	e.PUT("/users/:id", func(c *Context) {})
+	e.DELETE("/users/:id", func(c *Context) {})
+
+	// the host-agnostic routes are allowed as well
+	w := performHostRequest(e, http.MethodPost, "acme.example.com", "/users/1")
+	assert.Equal(http.StatusMethodNotAllowed, w.Code)
+	assert.Equal("DELETE, GET, HEAD, OPTIONS, PUT", w.Header().Get("Allow"))
+	w = performHostRequest(e, http.MethodPut, "acme.example.com", "/users/1")
+	assert.Equal(http.StatusOK, w.Code)
+
+	w = performHostRequest(e, http.MethodPost, "www.example.org", "/users/1")
+	assert.Equal("DELETE, OPTIONS, PUT", w.Header().Get("Allow"))
+}
+
+func TestStripPort(t *testing.T) {
+	tests := []struct {
+		host, want string
+	}{
+		{"example.com", "example.com"},
+		{"example.com:8080", "example.com"},
+		{"127.0.0.1:8080", "127.0.0.1"},
+		{"[::1]:8080", "::1"},
+		{"[::1]", "::1"},
+		{"::1", "::1"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, stripPort(tt.host), tt.host)
+	}
+}
@@ -1,10 +1,13 @@
 package gee
 
 import (
-	"log"
+	"fmt"
 	"time"
 )
 
+// Logger returns a middleware that logs the status, the URI and the latency
+// of each request to DefaultWriter. The private errors of the request are
+// written to DefaultErrorWriter, so that they stay out of the access log.
 func Logger() HandlerFunc {
 	return func(c *Context) {
 		// start time
@@ -14,6 +17,10 @@ func Logger() HandlerFunc {
 		c.Next()
 
 		// calculate resolution time
-		log.Printf("[%d] %s in %v", c.StatusCode, c.Req.RequestURI, time.Since(t))
+		fmt.Fprintf(DefaultWriter, "[GEE] %s | [%d] %s in %v\n",
+			t.Format("2006/01/02 - 15:04:05"), c.StatusCode, c.Req.RequestURI, time.Since(t))
+		if errs := c.Errors.ByType(ErrorTypePrivate); len(errs) > 0 {
+			fmt.Fprint(DefaultErrorWriter, errs.String())
+		}
 	}
 }
@@ -0,0 +1,28 @@
+package gee
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerPrivateErrors(t *testing.T) {
+	e := New()
+	e.Use(Logger())
+	e.GET("/users/:id", func(c *Context) {
+		c.Error(errors.New("cache miss"))
+		c.Error(errors.New("invalid id")).SetType(ErrorTypePublic)
+		c.String(http.StatusOK, "ok")
+	})
+	out, errOut := captureOutput(TestMode, func() {
+		performRequest(e, "GET", "/users/1")
+	})
+
+	// the access log has no errors, the private ones go to DefaultErrorWriter
+	assert.Contains(t, out, "[GEE] ")
+	assert.Contains(t, out, " | [200] /users/1 in ")
+	assert.NotContains(t, out, "cache miss")
+	assert.Equal(t, "Error #01: cache miss\n", errOut)
+}
@@ -0,0 +1,69 @@
+package gee
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// EnvGeeMode is the environment variable the mode is read from at startup.
+const EnvGeeMode = "GEE_MODE"
+
+const (
+	// DebugMode prints the registered routes, the framework's debug
+	// messages and warnings about risky settings. It is the default.
+	DebugMode = "debug"
+	// ReleaseMode prints none of the debug output.
+	ReleaseMode = "release"
+	// TestMode prints none of the debug output, for the tests of an application.
+	TestMode = "test"
+)
+
+const (
+	debugCode = iota
+	releaseCode
+	testCode
+)
+
+// DefaultWriter is the writer of the debug output.
+var DefaultWriter io.Writer = os.Stdout
+
+// DefaultErrorWriter is the writer of the warnings and the recovered panics.
+var DefaultErrorWriter io.Writer = os.Stderr
+
+var geeMode int32 = debugCode
+var modeName atomic.Value
+
+func init() {
+	SetMode(os.Getenv(EnvGeeMode))
+}
+
+// SetMode sets the mode of gee, an empty value selects DebugMode.
+// It panics for an unknown mode.
+func SetMode(value string) {
+	if value == "" {
+		value = DebugMode
+	}
+
+	switch value {
+	case DebugMode:
+		atomic.StoreInt32(&geeMode, debugCode)
+	case ReleaseMode:
+		atomic.StoreInt32(&geeMode, releaseCode)
+	case TestMode:
+		atomic.StoreInt32(&geeMode, testCode)
+	default:
+		panic("gee mode unknown: " + value + " (available modes: debug release test)")
+	}
+	modeName.Store(value)
+}
+
+// Mode returns the current mode of gee.
+func Mode() string {
+	return modeName.Load().(string)
+}
+
+// IsDebugging reports whether gee runs in DebugMode.
+func IsDebugging() bool {
+	return atomic.LoadInt32(&geeMode) == debugCode
+}
@@ -0,0 +1,96 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	SetMode(TestMode)
+}
+
+// captureOutput redirects DefaultWriter and DefaultErrorWriter while fn runs
+// in mode.
+func captureOutput(mode string, fn func()) (out, errOut string) {
+	var buf, errBuf strings.Builder
+	defaultWriter, defaultErrorWriter, oldMode := DefaultWriter, DefaultErrorWriter, Mode()
+	DefaultWriter, DefaultErrorWriter = &buf, &errBuf
+	SetMode(mode)
+	defer func() {
+		DefaultWriter, DefaultErrorWriter = defaultWriter, defaultErrorWriter
+		SetMode(oldMode)
+	}()
+
+	fn()
+	return buf.String(), errBuf.String()
+}
+
+func TestSetMode(t *testing.T) {
+	assert := assert.New(t)
+	defer SetMode(TestMode)
+
+	SetMode("")
+	assert.Equal(DebugMode, Mode())
+	assert.True(IsDebugging())
+	SetMode(ReleaseMode)
+	assert.Equal(ReleaseMode, Mode())
+	assert.False(IsDebugging())
+	SetMode(TestMode)
+	assert.Equal(TestMode, Mode())
+	assert.False(IsDebugging())
+	assert.Panics(func() { SetMode("prod") })
+}
+
+func TestModeGatesDebugOutput(t *testing.T) {
+	assert := assert.New(t)
+	register := func() {
+		e := New()
+		e.GET("/", handlerTest1)
+		e.GET("/status", func(c *Context) {
+			c.String(http.StatusOK, "ok")
+			c.Status(http.StatusCreated)
+		})
+		performRequest(e, "GET", "/status")
+	}
+
+	out, errOut := captureOutput(DebugMode, register)
+	assert.Contains(out, "GET    /    ")
+	assert.Equal("[GEE-debug] [WARNING] Headers were already written. Wanted to override status code 200 with 201\n", errOut)
+
+	for _, mode := range []string{ReleaseMode, TestMode} {
+		out, errOut = captureOutput(mode, register)
+		assert.Empty(out, mode)
+		assert.Empty(errOut, mode)
+	}
+}
+
+func TestDebugPrintSettingWarnings(t *testing.T) {
+	assert := assert.New(t)
+	e := New(WithTrustedProxies([]string{"0.0.0.0/0"}))
+	_, errOut := captureOutput(DebugMode, e.debugPrintSettingWarnings)
+	assert.Contains(errOut, `Running in "debug" mode`)
+	assert.Contains(errOut, "All proxies are trusted (0.0.0.0/0)")
+	assert.NotContains(errOut, "DynamicRouting")
+
+	e = New(WithTrustedProxies([]string{"10.0.0.0/8"}))
+	e.DynamicRouting = true
+	_, errOut = captureOutput(DebugMode, e.debugPrintSettingWarnings)
+	assert.NotContains(errOut, "All proxies")
+	assert.Contains(errOut, "DynamicRouting is enabled")
+
+	_, errOut = captureOutput(ReleaseMode, e.debugPrintSettingWarnings)
+	assert.Empty(errOut)
+
+	// the recovered panics are written in every mode
+	e = New()
+	e.Use(Recovery())
+	e.GET("/panic", func(c *Context) { panic("boom") })
+	_, errOut = captureOutput(ReleaseMode, func() {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+	})
+	assert.Contains(errOut, "panic recovered: boom")
+}
@@ -0,0 +1,73 @@
+package gee
+
+// OptionFunc configures an Engine, see New.
+type OptionFunc func(*Engine)
+
+// WithSecureJSONPrefix sets the prefix written by Context.SecureJSON,
+// "while(1);" by default.
+func WithSecureJSONPrefix(prefix string) OptionFunc {
+	return func(e *Engine) {
+		e.secureJSONPrefix = prefix
+	}
+}
+
+// WithMaxMultipartMemory sets the memory used to parse a multipart form,
+// the remaining parts are stored in temporary files. It is 32MB by default.
+func WithMaxMultipartMemory(n int64) OptionFunc {
+	return func(e *Engine) {
+		e.MaxMultipartMemory = n
+	}
+}
+
+// WithRedirectTrailingSlash enables or disables the redirect to the path
+// with (without) the trailing slash, it is enabled by default.
+// See Engine.RedirectTrailingSlash.
+func WithRedirectTrailingSlash(enabled bool) OptionFunc {
+	return func(e *Engine) {
+		e.RedirectTrailingSlash = enabled
+	}
+}
+
+// WithRedirectFixedPath enables or disables the redirect to the cleaned and
+// case-insensitively matched path, see Engine.RedirectFixedPath.
+func WithRedirectFixedPath(enabled bool) OptionFunc {
+	return func(e *Engine) {
+		e.RedirectFixedPath = enabled
+	}
+}
+
+// WithTrustedProxies sets the proxies trusted to report the client IP,
+// given as IPs or CIDRs. It panics if a proxy can not be parsed.
+// See Engine.SetTrustedProxies.
+func WithTrustedProxies(proxies []string) OptionFunc {
+	return func(e *Engine) {
+		if err := e.SetTrustedProxies(proxies); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// WithDelims sets the action delimiters of the templates loaded with
+// LoadHTMLGlob, "{{" and "}}" by default.
+func WithDelims(left, right string) OptionFunc {
+	return func(e *Engine) {
+		e.delims = [2]string{left, right}
+	}
+}
+
+// WithContextFallback enables or disables the delegation of the
+// context.Context methods of Context to the request context.
+// See Engine.ContextWithFallback.
+func WithContextFallback(enabled bool) OptionFunc {
+	return func(e *Engine) {
+		e.ContextWithFallback = enabled
+	}
+}
+
+// WithSecureCookie sets the SecureCookie for the signed and encrypted
+// cookies, see NewSecureCookie.
+func WithSecureCookie(s *SecureCookie) OptionFunc {
+	return func(e *Engine) {
+		e.SecureCookie = s
+	}
+}
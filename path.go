@@ -0,0 +1,23 @@
+package gee
+
+import "path"
+
+// cleanPath is the URL version of path.Clean, it returns a canonical URL path
+// for p, eliminating . and .. elements and repeated slashes. The result always
+// begins with a slash and keeps the trailing slash of p.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+
+	cp := p
+	if cp[0] != '/' {
+		cp = "/" + cp
+	}
+	cp = path.Clean(cp)
+
+	if p[len(p)-1] == '/' && cp != "/" {
+		cp += "/"
+	}
+	return cp
+}
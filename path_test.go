@@ -0,0 +1,28 @@
+package gee
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		path, result string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"//", "/"},
+		{"abc", "/abc"},
+		{"/abc/", "/abc/"},
+		{"//abc//def", "/abc/def"},
+		{"/abc/./def", "/abc/def"},
+		{"/abc/../def/", "/def/"},
+		{"/../abc", "/abc"},
+		{"/abc/def/..", "/abc"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.result, cleanPath(tt.path), tt.path)
+	}
+}
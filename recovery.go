@@ -0,0 +1,34 @@
+package gee
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// Recovery returns a middleware that recovers from panics in the handlers
+// after it. The panic is written to DefaultErrorWriter with its stack trace
+// and the client gets a 500 response, unless the response was already written.
+func Recovery() HandlerFunc {
+	return func(c *Context) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				// the handler aborted the response on purpose
+				panic(err)
+			}
+
+			fmt.Fprintf(DefaultErrorWriter, "[Recovery] %s panic recovered: %v\n%s\n",
+				time.Now().Format("2006/01/02 - 15:04:05"), err, debug.Stack())
+			if !c.Writer.Written() {
+				c.String(http.StatusInternalServerError, "500 INTERNAL SERVER ERROR\n")
+			}
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,51 @@
+package gee
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecovery(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	defaultErrorWriter := DefaultErrorWriter
+	DefaultErrorWriter = &buf
+	defer func() { DefaultErrorWriter = defaultErrorWriter }()
+
+	var after bool
+	e := New()
+	e.Use(func(c *Context) {
+		c.Next()
+		after = true
+	})
+	e.Use(Recovery())
+	e.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+	e.GET("/written", func(c *Context) {
+		c.String(http.StatusOK, "partial")
+		panic("boom")
+	})
+
+	w := performRequest(e, "GET", "/panic")
+	assert.Equal(http.StatusInternalServerError, w.Code)
+	assert.Equal("500 INTERNAL SERVER ERROR\n", w.Body.String())
+	assert.True(after)
+	assert.Contains(buf.String(), "panic recovered: boom")
+	assert.Contains(buf.String(), "recovery_test.go")
+
+	// a written response is kept
+	w = performRequest(e, "GET", "/written")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("partial", w.Body.String())
+
+	e.GET("/abort", func(c *Context) {
+		panic(http.ErrAbortHandler)
+	})
+	assert.PanicsWithValue(http.ErrAbortHandler, func() {
+		performRequest(e, "GET", "/abort")
+	})
+}
@@ -3,7 +3,6 @@ package gee
 import (
 	"bufio"
 	"io"
-	"log"
 	"net"
 	"net/http"
 )
@@ -68,7 +67,7 @@ func (w *responseWriter) Written() bool {
 func (w *responseWriter) WriteHeader(code int) {
 	if code > 0 && w.status != code {
 		if w.Written() {
-			log.Printf("[WARING] Headers were already written, Wanted to override status code %d with %d", w.status, code)
+			debugPrintWarning("Headers were already written. Wanted to override status code %d with %d", w.status, code)
 		}
 		w.status = code
 	}
@@ -119,3 +118,38 @@ func (w *responseWriter) Pusher() http.Pusher {
 	}
 	return nil
 }
+
+// headResponseWriter discards the response body,
+// it is used when a HEAD request is served by a GET route.
+type headResponseWriter struct {
+	ResponseWriter
+}
+
+// Write writes the header but drops the data.
+func (w *headResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	return len(data), nil
+}
+
+// WriteString writes the header but drops the string.
+func (w *headResponseWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	return len(s), nil
+}
+
+// releasedWriter is the writer of a released context, it panics on every use.
+type releasedWriter struct{}
+
+var _ ResponseWriter = releasedWriter{}
+
+func (releasedWriter) Header() http.Header                          { panic(errContextReleased) }
+func (releasedWriter) Write([]byte) (int, error)                    { panic(errContextReleased) }
+func (releasedWriter) WriteHeader(int)                              { panic(errContextReleased) }
+func (releasedWriter) WriteString(string) (int, error)              { panic(errContextReleased) }
+func (releasedWriter) WriteHeaderNow()                              { panic(errContextReleased) }
+func (releasedWriter) Status() int                                  { panic(errContextReleased) }
+func (releasedWriter) Size() int                                    { panic(errContextReleased) }
+func (releasedWriter) Written() bool                                { panic(errContextReleased) }
+func (releasedWriter) Flush()                                       { panic(errContextReleased) }
+func (releasedWriter) Pusher() http.Pusher                          { panic(errContextReleased) }
+func (releasedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { panic(errContextReleased) }
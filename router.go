@@ -2,90 +2,232 @@ package gee
 
 import (
 	"net/http"
+	"sort"
 	"strings"
 )
 
 type router struct {
-	roots    map[string]*node
-	handlers map[string]HandlerFunc
+	roots map[string]*node
+
+	// maxParams is the largest number of wildcards of any registered route,
+	// used to preallocate the params of a lookup.
+	maxParams int
 }
 
 func newRouter() *router {
 	return &router{
-		roots:    make(map[string]*node),
-		handlers: make(map[string]HandlerFunc),
+		roots: make(map[string]*node),
 	}
 }
 
-// only one * is allowed
-func parsePattern(pattern string) []string {
-	vs := strings.Split(pattern, "/")
+func (r *router) addRouter(method, pattern string, handlers []HandlerFunc) {
+	if pattern == "" || pattern[0] != '/' {
+		panic("path must begin with '/': " + pattern)
+	}
+
+	root, ok := r.roots[method]
+	if !ok {
+		root = &node{}
+		r.roots[method] = root
+	}
+	root.insert(pattern, handlers)
+
+	if n := countParams(pattern); n > r.maxParams {
+		r.maxParams = n
+	}
+}
 
-	parts := make([]string, 0)
-	for _, item := range vs {
-		if item != "" {
-			parts = append(parts, item)
+// removeRouter unregisters the route of method and pattern and reports
+// whether it was registered. maxParams is kept, it stays an upper bound.
+func (r *router) removeRouter(method, pattern string) bool {
+	root, ok := r.roots[method]
+	if !ok || !root.remove(pattern, 0) {
+		return false
+	}
+	if root.isEmpty() {
+		delete(r.roots, method)
+	}
+	return true
+}
 
-			// check specified *
-			if item[0] == '*' {
-				break
+// hasPattern reports whether a route of any method has pattern.
+func (r *router) hasPattern(pattern string) bool {
+	for method, root := range r.roots {
+		for _, route := range root.iterate(method, nil) {
+			if route.Path == pattern {
+				return true
 			}
 		}
 	}
-	return parts
+	return false
+}
+
+// clone returns a copy of the router in which the tree of method can be
+// modified while the original is still being read. The trees of the other
+// methods are shared.
+func (r *router) clone(method string) *router {
+	c := &router{
+		roots:     make(map[string]*node, len(r.roots)+1),
+		maxParams: r.maxParams,
+	}
+	for m, root := range r.roots {
+		c.roots[m] = root
+	}
+	if root, ok := r.roots[method]; ok {
+		c.roots[method] = root.clone()
+	}
+	return c
 }
 
-func genKey(method, pattern string) string {
-	return method + "-" + pattern
+// getRouter returns the node matched by method and path and appends the URL
+// parameters to params. Given params with a capacity of maxParams the lookup
+// does not allocate.
+func (r *router) getRouter(method, path string, params *Params) *node {
+	root, ok := r.roots[method]
+	if !ok {
+		return nil
+	}
+	return root.search(path, params)
 }
 
-func (r *router) addRouter(method, pattern string, handler HandlerFunc) {
-	parts := parsePattern(pattern)
+// RouteInfo represents a registered route.
+type RouteInfo struct {
+	Method string
+	Path   string
+	Host   string // the host pattern, empty for host-agnostic routes
 
-	key := genKey(method, pattern)
-	_, ok := r.roots[method]
-	if !ok {
-		r.roots[method] = &node{}
+	// Handler is the function name of the route handler,
+	// NumHandlers the length of the chain including the middleware.
+	Handler     string
+	HandlerFunc HandlerFunc
+	NumHandlers int
+}
+
+// routes returns the registered routes, sorted by path and method.
+func (r *router) routes() []RouteInfo {
+	var routes []RouteInfo
+	for method, root := range r.roots {
+		routes = root.iterate(method, routes)
 	}
-	r.roots[method].insert(pattern, parts, 0)
-	r.handlers[key] = handler
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
 }
 
-func (r *router) getRouter(method, path string) (*node, map[string]string) {
-	searchParts := parsePattern(path)
-	params := make(map[string]string)
+// allowedMethods appends the methods with a route for path to methods.
+func (r *router) allowedMethods(path string, params *Params, methods []string) []string {
+	for method, root := range r.roots {
+		i := len(*params)
+		if root.search(path, params) != nil {
+			methods = append(methods, method)
+		}
+		*params = (*params)[:i]
+	}
+	return methods
+}
+
+// allowHeader returns the value of the Allow header for the methods with a
+// route, or an empty string if there are none. methods may have duplicates.
+func allowHeader(methods []string) string {
+	if len(methods) == 0 {
+		return ""
+	}
+
+	// HEAD is served by GET routes and OPTIONS is answered automatically
+	hasMethod := func(method string) bool {
+		for _, m := range methods {
+			if m == method {
+				return true
+			}
+		}
+		return false
+	}
+	if hasMethod(http.MethodGet) && !hasMethod(http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+	}
+	if !hasMethod(http.MethodOptions) {
+		methods = append(methods, http.MethodOptions)
+	}
+	sort.Strings(methods)
+	j := 0
+	for i, m := range methods {
+		if i == 0 || m != methods[j-1] {
+			methods[j] = m
+			j++
+		}
+	}
+	return strings.Join(methods[:j], ", ")
+}
+
+// redirectPath returns the path of the route a request for path should be
+// redirected to, according to the redirect settings of the engine.
+func (r *router) redirectPath(e *Engine, method, path string, params *Params) (string, bool) {
+	if method == http.MethodConnect || path == "/" {
+		return "", false
+	}
 	root, ok := r.roots[method]
 	if !ok {
-		return nil, nil
-	}
-	n := root.search(searchParts, 0)
-	if n != nil {
-		parts := parsePattern(n.pattern)
-		for index, part := range parts {
-			if part[0] == ':' {
-				params[part[1:]] = searchParts[index]
-			}
-			if part[0] == '*' && len(part) > 1 {
-				params[part[1:]] = strings.Join(searchParts[index:], "/")
-				break
-			}
+		return "", false
+	}
+
+	if e.RedirectTrailingSlash {
+		tsrPath := path + "/"
+		if path[len(path)-1] == '/' {
+			tsrPath = path[:len(path)-1]
+		}
+		i := len(*params)
+		n := root.search(tsrPath, params)
+		*params = (*params)[:i]
+		if n != nil {
+			return tsrPath, true
 		}
-		return n, params
 	}
-	return nil, nil
+
+	if e.RedirectFixedPath {
+		return root.findCaseInsensitivePath(cleanPath(path), e.RedirectTrailingSlash)
+	}
+	return "", false
 }
 
-func (r *router) handle(c *Context) {
-	n, params := r.getRouter(c.Method, c.Path)
+// redirectRequest redirects to location, permanently for GET requests
+// and with 308 for the other methods, so that they keep method and body.
+func redirectRequest(c *Context, location string) {
+	code := http.StatusMovedPermanently
+	if c.Method != http.MethodGet {
+		code = http.StatusPermanentRedirect
+	}
+	if c.Req.URL.RawQuery != "" {
+		location += "?" + c.Req.URL.RawQuery
+	}
+
+	debugPrint("redirecting request %d: %s --> %s", code, c.Path, location)
+	c.Status(code)
+	http.Redirect(c.Writer, c.Req, location, code)
+}
 
-	if n != nil {
-		c.Params = params
-		key := genKey(c.Method, n.pattern)
-		c.handlers = append(c.handlers, r.handlers[key])
-	} else {
-		c.handlers = append(c.handlers, func(c *Context) {
-			c.String(http.StatusNotFound, "404 NOT FOUND: %s %s \n", c.Method, c.Path)
-		})
+// lookup returns the route for method and path. HEAD requests fall back to
+// the GET routes, which is reported by fromGet.
+func (r *router) lookup(method, path string, params *Params) (n *node, fromGet bool) {
+	n = r.getRouter(method, path, params)
+	if n == nil && method == http.MethodHead {
+		n = r.getRouter(http.MethodGet, path, params)
+		fromGet = n != nil
 	}
+	return
+}
+
+// serveError runs the NoRoute or NoMethod handler chain with code as the
+// preset status. The default message is written if the chain wrote nothing
+// and kept the status.
+func serveError(c *Context, code int, defaultMessage string) {
+	c.Status(code)
 	c.Next()
+	if c.Writer.Written() || c.Writer.Status() != code {
+		return
+	}
+	c.String(code, defaultMessage, c.Method, c.Path)
 }
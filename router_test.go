@@ -7,13 +7,6 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestParsePattern(t *testing.T) {
-	assert := assert.New(t)
-	assert.Equal(parsePattern("/name/:id"), []string{"name", ":id"})
-	assert.Equal(parsePattern("/name/*"), []string{"name", "*"})
-	assert.Equal(parsePattern("/name/*id/*"), []string{"name", "*id"})
-}
-
 func TestGetRouter(t *testing.T) {
 	r := newRouter()
 	assert := assert.New(t)
@@ -22,7 +15,7 @@ func TestGetRouter(t *testing.T) {
 	pathCaseA := "/identity/v3/user"
 	pathCaseB := "/identity/v3/user/:id"
 	pathCaseC := "/identity/v3/user/:id/group"
-	pathCaseD := "/identity/v3/*path"
+	pathCaseD := "/identity/v3/files/*path"
 
 	// add router
 	r.addRouter(getMethod, pathCaseA, nil)
@@ -31,23 +24,147 @@ func TestGetRouter(t *testing.T) {
 	r.addRouter(getMethod, pathCaseD, nil)
 
 	// get router
-	n, params := r.getRouter(getMethod, "/identity/v3/user")
+	params := make(Params, 0, r.maxParams)
+	n := r.getRouter(getMethod, "/identity/v3/user", &params)
 	assert.NotNil(n)
 	assert.Equal(pathCaseA, n.pattern)
 	assert.Equal(0, len(params))
 
-	n, params = r.getRouter(getMethod, "/identity/v3/user/10086")
+	params = params[:0]
+	n = r.getRouter(getMethod, "/identity/v3/user/10086", &params)
 	assert.NotNil(n)
 	assert.Equal(pathCaseB, n.pattern)
-	assert.Equal("10086", params["id"])
+	assert.Equal(Params{{Key: "id", Value: "10086"}}, params)
 
-	n, params = r.getRouter(getMethod, "/identity/v3/user/10086/group")
+	params = params[:0]
+	n = r.getRouter(getMethod, "/identity/v3/user/10086/group", &params)
 	assert.NotNil(n)
 	assert.Equal(pathCaseC, n.pattern)
-	assert.Equal("10086", params["id"])
+	assert.Equal(Params{{Key: "id", Value: "10086"}}, params)
 
-	n, params = r.getRouter(getMethod, "/identity/v3/helloAllPath/paths")
+	params = params[:0]
+	n = r.getRouter(getMethod, "/identity/v3/files/helloAllPath/paths", &params)
 	assert.NotNil(n)
 	assert.Equal(pathCaseD, n.pattern)
-	assert.Equal("helloAllPath/paths", params["path"])
+	assert.Equal(Params{{Key: "path", Value: "helloAllPath/paths"}}, params)
+
+	params = params[:0]
+	assert.Nil(r.getRouter(http.MethodPost, "/identity/v3/user", &params))
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	assert := assert.New(t)
+	handler := func(c *Context) {
+		c.String(http.StatusOK, c.Method)
+	}
+
+	e := New()
+	e.GET("/users/:id", handler)
+	e.DELETE("/users/:id", handler)
+	e.PUT("/users/*path", handler)
+	e.GET("/cors", handler)
+	e.OPTIONS("/cors", func(c *Context) {
+		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		c.Status(http.StatusOK)
+	})
+
+	// disabled by default
+	w := performRequest(e, http.MethodPost, "/users/1")
+	assert.Equal(http.StatusNotFound, w.Code)
+	assert.Empty(w.Header().Get("Allow"))
+
+	e.HandleMethodNotAllowed = true
+	w = performRequest(e, http.MethodPost, "/users/1")
+	assert.Equal(http.StatusMethodNotAllowed, w.Code)
+	assert.Equal("DELETE, GET, HEAD, OPTIONS, PUT", w.Header().Get("Allow"))
+
+	w = performRequest(e, http.MethodPost, "/users/1/posts")
+	assert.Equal(http.StatusMethodNotAllowed, w.Code)
+	assert.Equal("OPTIONS, PUT", w.Header().Get("Allow"))
+
+	w = performRequest(e, http.MethodPost, "/missing")
+	assert.Equal(http.StatusNotFound, w.Code)
+	assert.Empty(w.Header().Get("Allow"))
+
+	w = performRequest(e, http.MethodOptions, "/users/1")
+	assert.Equal(http.StatusNoContent, w.Code)
+	assert.Equal("DELETE, GET, HEAD, OPTIONS, PUT", w.Header().Get("Allow"))
+	assert.Empty(w.Body.String())
+
+	// an explicit OPTIONS route wins
+	w = performRequest(e, http.MethodOptions, "/cors")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(w.Header().Get("Allow"))
+
+	w = performRequest(e, http.MethodPost, "/cors")
+	assert.Equal("GET, HEAD, OPTIONS", w.Header().Get("Allow"))
+}
+
+func TestRedirectTrailingSlash(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	e.GET("/users", func(c *Context) {})
+	e.POST("/posts/", func(c *Context) {})
+	e.GET("/users/:id/", func(c *Context) {})
+
+	w := performRequest(e, http.MethodGet, "/users/?page=2")
+	assert.Equal(http.StatusMovedPermanently, w.Code)
+	assert.Equal("/users?page=2", w.Header().Get("Location"))
+
+	w = performRequest(e, http.MethodPost, "/posts")
+	assert.Equal(http.StatusPermanentRedirect, w.Code)
+	assert.Equal("/posts/", w.Header().Get("Location"))
+
+	w = performRequest(e, http.MethodGet, "/users/10")
+	assert.Equal(http.StatusMovedPermanently, w.Code)
+	assert.Equal("/users/10/", w.Header().Get("Location"))
+
+	w = performRequest(e, http.MethodHead, "/users/")
+	assert.Equal(http.StatusPermanentRedirect, w.Code)
+	assert.Equal("/users", w.Header().Get("Location"))
+
+	e.RedirectTrailingSlash = false
+	w = performRequest(e, http.MethodGet, "/users/")
+	assert.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestRedirectFixedPath(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	e.RedirectFixedPath = true
+	e.GET("/Users/:name/Posts", func(c *Context) {})
+	e.GET("/files/*path", func(c *Context) {})
+	e.PUT("/docs/", func(c *Context) {})
+
+	w := performRequest(e, http.MethodGet, "/users/Gee/posts")
+	assert.Equal(http.StatusMovedPermanently, w.Code)
+	assert.Equal("/Users/Gee/Posts", w.Header().Get("Location"))
+
+	w = performRequest(e, http.MethodGet, "/USERS//gee/./../gee/POSTS/")
+	assert.Equal(http.StatusMovedPermanently, w.Code)
+	assert.Equal("/Users/gee/Posts", w.Header().Get("Location"))
+
+	w = performRequest(e, http.MethodGet, "/FILES/Readme.md")
+	assert.Equal(http.StatusMovedPermanently, w.Code)
+	assert.Equal("/files/Readme.md", w.Header().Get("Location"))
+
+	w = performRequest(e, http.MethodPut, "/./DOCS")
+	assert.Equal(http.StatusPermanentRedirect, w.Code)
+	assert.Equal("/docs/", w.Header().Get("Location"))
+
+	w = performRequest(e, http.MethodGet, "/missing")
+	assert.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestRemoveExtraSlash(t *testing.T) {
+	e := New()
+	e.RemoveExtraSlash = true
+	e.GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, c.Param("id"))
+	})
+
+	w := performRequest(e, http.MethodGet, "//users///10")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "10", w.Body.String())
 }
@@ -1,9 +1,10 @@
 package gee
 
 import (
-	"log"
 	"net/http"
+	"net/url"
 	"path"
+	"strings"
 )
 
 type RouterGroup struct {
@@ -11,6 +12,7 @@ type RouterGroup struct {
 	handlers []HandlerFunc
 	parent   *RouterGroup
 	engine   *Engine
+	host     string // the host pattern, empty for host-agnostic routes
 }
 
 func (group *RouterGroup) Group(prefix string) *RouterGroup {
@@ -19,27 +21,141 @@ func (group *RouterGroup) Group(prefix string) *RouterGroup {
 		prefix: group.prefix + prefix,
 		parent: group,
 		engine: engine,
+		host:   group.host,
 	}
-	engine.groups = append(engine.groups, newGroup)
 	return newGroup
 }
 
-func (group *RouterGroup) addRoute(method, comp string, handler HandlerFunc) {
+// combineHandlers returns the handler chain of a route registered on the group,
+// the middleware of the outermost group comes first and the route handlers last.
+func (group *RouterGroup) combineHandlers(handlers []HandlerFunc) []HandlerFunc {
+	var groups []*RouterGroup
+	size := len(handlers)
+	for g := group; g != nil; g = g.parent {
+		groups = append(groups, g)
+		size += len(g.handlers)
+	}
+
+	chain := make([]HandlerFunc, 0, size)
+	for i := len(groups) - 1; i >= 0; i-- {
+		chain = append(chain, groups[i].handlers...)
+	}
+	return append(chain, handlers...)
+}
+
+// Route is returned when a route is registered, it allows to name the route.
+type Route struct {
+	engine  *Engine
+	pattern string
+}
+
+// Name names the route, so that its URL can be built with Engine.URLFor.
+// The name must be unique.
+func (r *Route) Name(name string) *Route {
+	e := r.engine
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if pattern, ok := e.namedRoutes[name]; ok {
+		panic("route name '" + name + "' is already used by '" + pattern + "'")
+	}
+	e.namedRoutes[name] = r.pattern
+	return r
+}
+
+func (group *RouterGroup) addRoute(method, comp string, handlers []HandlerFunc) *Route {
 	pattern := group.prefix + comp
-	log.Printf("Route %4s - %s", method, pattern)
-	group.engine.router.addRouter(method, pattern, handler)
+	group.engine.addRoute(group, method, pattern, handlers)
+	return &Route{engine: group.engine, pattern: pattern}
+}
+
+// Handle registers a new request handle with the given path and method.
+// The last handler should be the real handler, the other ones should be
+// middleware that is run for this route only.
+// A param may be constrained as ":id<int>", ":uuid<uuid>" or with a regular
+// expression like ":slug<[a-z0-9-]+>", segments that do not satisfy the
+// constraint fall through to the other routes.
+// For GET, POST, PUT, PATCH and DELETE requests the respective shortcut
+// functions can be used, Handle is meant for less frequent or non-standard
+// methods.
+func (group *RouterGroup) Handle(method, pattern string, handlers ...HandlerFunc) *Route {
+	if method == "" {
+		panic("HTTP method can not be empty")
+	}
+	return group.addRoute(method, pattern, handlers)
+}
+
+// GET is a shortcut for group.Handle("GET", pattern, handlers).
+// The route also answers HEAD requests unless a HEAD route is registered.
+func (group *RouterGroup) GET(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute(http.MethodGet, pattern, handlers)
+}
+
+// POST is a shortcut for group.Handle("POST", pattern, handlers).
+func (group *RouterGroup) POST(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute(http.MethodPost, pattern, handlers)
 }
 
-func (group *RouterGroup) GET(pattern string, handler HandlerFunc) {
-	group.addRoute("GET", pattern, handler)
+// DELETE is a shortcut for group.Handle("DELETE", pattern, handlers).
+func (group *RouterGroup) DELETE(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute(http.MethodDelete, pattern, handlers)
 }
 
-func (group *RouterGroup) POST(pattern string, handler HandlerFunc) {
-	group.addRoute("POST", pattern, handler)
+// PATCH is a shortcut for group.Handle("PATCH", pattern, handlers).
+func (group *RouterGroup) PATCH(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute(http.MethodPatch, pattern, handlers)
 }
 
-// add middleware
+// PUT is a shortcut for group.Handle("PUT", pattern, handlers).
+func (group *RouterGroup) PUT(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute(http.MethodPut, pattern, handlers)
+}
+
+// OPTIONS is a shortcut for group.Handle("OPTIONS", pattern, handlers).
+func (group *RouterGroup) OPTIONS(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute(http.MethodOptions, pattern, handlers)
+}
+
+// HEAD is a shortcut for group.Handle("HEAD", pattern, handlers).
+func (group *RouterGroup) HEAD(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute(http.MethodHead, pattern, handlers)
+}
+
+// CONNECT is a shortcut for group.Handle("CONNECT", pattern, handlers).
+func (group *RouterGroup) CONNECT(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute(http.MethodConnect, pattern, handlers)
+}
+
+// TRACE is a shortcut for group.Handle("TRACE", pattern, handlers).
+func (group *RouterGroup) TRACE(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute(http.MethodTrace, pattern, handlers)
+}
+
+// anyMethods are the methods registered by Any.
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodHead, http.MethodOptions, http.MethodDelete, http.MethodConnect,
+	http.MethodTrace,
+}
+
+// Any registers a route that matches all the HTTP methods.
+func (group *RouterGroup) Any(pattern string, handlers ...HandlerFunc) *Route {
+	var route *Route
+	for _, method := range anyMethods {
+		route = group.addRoute(method, pattern, handlers)
+	}
+	return route
+}
+
+// Use adds middleware to the group. The handler chain of a route is bound
+// when the route is registered, so middleware only applies to the routes
+// registered after it. The chain of a route runs in this order:
+//
+//  1. the middleware of the engine, in the order of the Use calls
+//  2. the middleware of each group, from the outermost to the innermost
+//  3. the handlers given to the route, the last one being the route handler
 func (group *RouterGroup) Use(middleware ...HandlerFunc) {
+	group.engine.mu.Lock()
+	defer group.engine.mu.Unlock()
 	group.handlers = append(group.handlers, middleware...)
 }
 
@@ -59,3 +175,58 @@ func (group *RouterGroup) Static(relativePath, root string) {
 	// Register GET Handlers
 	group.GET(urlPattern, handler)
 }
+
+// Mount serves the requests for prefix and every path below it with handler,
+// for all the methods registered by Any. The path matched by the group prefix
+// and prefix is stripped from the request path before handler is called, so it
+// sees "/" for the prefix itself, also below the params of a group like
+// "/tenants/:id". handler may be another *Engine, so that separately built
+// modules can be composed into one server behind the middleware of the group.
+func (group *RouterGroup) Mount(prefix string, handler http.Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	h := mountHandler(handler)
+	if prefix != "" {
+		group.Any(prefix, h)
+	}
+	group.Any(prefix+"/*mountpath", h)
+}
+
+// mountHandler calls handler with the request path reduced to the mountpath
+// param, like http.StripPrefix, but leaves "/" instead of an empty path.
+func mountHandler(handler http.Handler) HandlerFunc {
+	return func(c *Context) {
+		path := "/" + c.Param("mountpath")
+		req := c.Req
+		if e := c.engine; e.UseRawPath && !e.UnescapePathValues && req.URL.RawPath != "" {
+			// the param was taken from the escaped path
+			if p, err := url.PathUnescape(path); err == nil {
+				path = p
+			}
+		}
+
+		r := new(http.Request)
+		*r = *req
+		r.URL = new(url.URL)
+		*r.URL = *req.URL
+		r.URL.Path = path
+		r.URL.RawPath = ""
+		if req.URL.RawPath != "" {
+			r.URL.RawPath = rawSuffix(req.URL.RawPath, path)
+		}
+		handler.ServeHTTP(c.Writer, r)
+	}
+}
+
+// rawSuffix returns the suffix of the escaped path raw that starts with a '/'
+// and is the escaped form of path, or "" if there is none.
+func rawSuffix(raw, path string) string {
+	for i := len(raw) - 1; i >= 0; i-- {
+		if raw[i] != '/' {
+			continue
+		}
+		if p, err := url.PathUnescape(raw[i:]); err == nil && p == path {
+			return raw[i:]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,151 @@
+package gee
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	var trace []string
+	mark := func(name string) HandlerFunc {
+		return func(c *Context) {
+			trace = append(trace, name)
+		}
+	}
+
+	e := New()
+	e.Use(mark("engine1"), mark("engine2"))
+	v1 := e.Group("/v1")
+	v1.Use(mark("v1"))
+	admin := v1.Group("/admin")
+	admin.Use(mark("admin"))
+	admin.GET("/users", mark("route"), mark("handler"))
+
+	performRequest(e, http.MethodGet, "/v1/admin/users")
+	assert.Equal(t, []string{"engine1", "engine2", "v1", "admin", "route", "handler"}, trace)
+
+	// middleware only applies to the routes registered after it
+	trace = nil
+	v1.Use(mark("late"))
+	admin.GET("/late", mark("handler"))
+	performRequest(e, http.MethodGet, "/v1/admin/users")
+	assert.Equal(t, []string{"engine1", "engine2", "v1", "admin", "route", "handler"}, trace)
+
+	trace = nil
+	performRequest(e, http.MethodGet, "/v1/admin/late")
+	assert.Equal(t, []string{"engine1", "engine2", "v1", "late", "admin", "handler"}, trace)
+}
+
+func TestGroupMiddlewareScope(t *testing.T) {
+	var trace []string
+	mark := func(name string) HandlerFunc {
+		return func(c *Context) {
+			trace = append(trace, name)
+		}
+	}
+
+	e := New()
+	e.Use(mark("engine"))
+	e.Group("/v1").Use(mark("v1"))
+	e.GET("/v10/users", mark("v10"))
+
+	// group /v1 does not apply to /v10
+	performRequest(e, http.MethodGet, "/v10/users")
+	assert.Equal(t, []string{"engine", "v10"}, trace)
+
+	// group middleware does not run on unknown paths, the engine's does
+	trace = nil
+	w := performRequest(e, http.MethodGet, "/v1/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, []string{"engine"}, trace)
+}
+
+func TestGroupMount(t *testing.T) {
+	assert := assert.New(t)
+
+	sub := New()
+	sub.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "sub index")
+	})
+	sub.GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "sub user %s", c.Param("id"))
+	})
+
+	var trace []string
+	e := New()
+	admin := e.Group("/admin")
+	admin.Use(func(c *Context) {
+		trace = append(trace, c.Path)
+	})
+	admin.Mount("/sub", sub)
+	e.Mount("/metrics/", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("metrics " + req.URL.Path))
+	}))
+	e.GET("/legacy", WrapF(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	w := performRequest(e, http.MethodGet, "/admin/sub")
+	assert.Equal("sub index", w.Body.String())
+
+	w = performRequest(e, http.MethodGet, "/admin/sub/users/7")
+	assert.Equal("sub user 7", w.Body.String())
+	assert.Equal([]string{"/admin/sub", "/admin/sub/users/7"}, trace)
+
+	w = performRequest(e, http.MethodPost, "/admin/sub/users/7")
+	assert.Equal(http.StatusNotFound, w.Code)
+
+	w = performRequest(e, http.MethodGet, "/metrics/cpu")
+	assert.Equal("metrics /cpu", w.Body.String())
+	w = performRequest(e, http.MethodGet, "/metrics")
+	assert.Equal("metrics /", w.Body.String())
+
+	w = performRequest(e, http.MethodGet, "/legacy")
+	assert.Equal(http.StatusAccepted, w.Code)
+}
+
+func TestGroupMountParams(t *testing.T) {
+	assert := assert.New(t)
+
+	var rawPath string
+	sub := New()
+	sub.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "storage index")
+	})
+	sub.GET("/files/:name", func(c *Context) {
+		rawPath = c.Req.URL.RawPath
+		c.String(http.StatusOK, "file %s", c.Param("name"))
+	})
+
+	var tenant string
+	e := New()
+	tenants := e.Group("/tenants/:id")
+	tenants.Use(func(c *Context) {
+		tenant = c.Param("id")
+	})
+	tenants.Mount("/storage", sub)
+	e.Mount("/static", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("static " + req.URL.Path))
+	}))
+
+	w := performRequest(e, http.MethodGet, "/tenants/acme/storage/files/a.txt")
+	assert.Equal("file a.txt", w.Body.String())
+	assert.Equal("acme", tenant)
+	assert.Equal("", rawPath)
+
+	// the escaped path is reduced as well
+	e.UseRawPath, sub.UseRawPath = true, true
+	w = performRequest(e, http.MethodGet, "/tenants/ac%2Fme/storage/files/a%2Fb")
+	assert.Equal("file a/b", w.Body.String())
+	assert.Equal("ac/me", tenant)
+	assert.Equal("/files/a%2Fb", rawPath)
+
+	w = performRequest(e, http.MethodGet, "/tenants/other/storage")
+	assert.Equal("storage index", w.Body.String())
+	assert.Equal("other", tenant)
+
+	w = performRequest(e, http.MethodGet, "/static/css/site.css")
+	assert.Equal("static /css/site.css", w.Body.String())
+}
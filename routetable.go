@@ -0,0 +1,185 @@
+package gee
+
+import "strings"
+
+// routeTable is a snapshot of the routing state of an engine. Requests are
+// served from the current snapshot without locking. Once the server started,
+// or at any time with DynamicRouting, each change copies the parts it
+// modifies and swaps in a new snapshot atomically. Otherwise the routers are
+// modified in place.
+type routeTable struct {
+	router *router
+
+	// the routers of the host patterns, see Host
+	hosts []*hostRouter
+
+	// the global middleware, run for the automatic OPTIONS responses
+	global []HandlerFunc
+
+	// handler chains for unmatched requests, prefixed with the global middleware
+	allNoRoute  []HandlerFunc
+	allNoMethod []HandlerFunc
+
+	// copyOnWrite is set by updateTable when the snapshot may be read by a
+	// concurrent request, see mutableRouter.
+	copyOnWrite bool
+}
+
+// loadTable returns the current route table.
+func (e *Engine) loadTable() *routeTable {
+	return e.table.Load().(*routeTable)
+}
+
+// updateTable calls fn with a shallow copy of the current route table and
+// stores it as the new one. fn must get the routers it modifies from
+// mutableRouter. It panics if the routes are frozen.
+func (e *Engine) updateTable(fn func(t *routeTable)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.started && !e.DynamicRouting {
+		panic("routes can not be changed after the server started, enable DynamicRouting to change them at runtime")
+	}
+
+	t := *e.loadTable()
+	// an engine may serve requests without a Run function, like behind
+	// http.Server or Mount, so with DynamicRouting it is always copied
+	t.copyOnWrite = e.started || e.DynamicRouting
+	fn(&t)
+	e.table.Store(&t)
+}
+
+// freeze marks the server as started, from then on the routes may only be
+// changed if DynamicRouting is enabled.
+func (e *Engine) freeze() {
+	e.mu.Lock()
+	e.started = true
+	e.mu.Unlock()
+}
+
+// mutableRouter returns the router of host, "" being the host-agnostic
+// router, whose tree of method can be modified. While the server runs the
+// router is replaced in t with a copy. Without DynamicRouting nothing reads the
+// routes concurrently before the start, so they are modified in place, as
+// copying each time would make the registration of n routes cost O(n²).
+func (t *routeTable) mutableRouter(host, method string) *router {
+	if !t.copyOnWrite {
+		if r := t.routerOf(host); r != nil {
+			return r
+		}
+		panic("no router for host '" + host + "'")
+	}
+
+	if host == "" {
+		t.router = t.router.clone(method)
+		return t.router
+	}
+
+	hosts := make([]*hostRouter, len(t.hosts))
+	copy(hosts, t.hosts)
+	t.hosts = hosts
+	for i, h := range hosts {
+		if h.pattern == host {
+			hc := *h
+			hc.router = h.router.clone(method)
+			hosts[i] = &hc
+			return hc.router
+		}
+	}
+	panic("no router for host '" + host + "'")
+}
+
+// routerOf returns the router of host, "" being the host-agnostic router,
+// or nil if Host was not called for it.
+func (t *routeTable) routerOf(host string) *router {
+	if host == "" {
+		return t.router
+	}
+	for _, h := range t.hosts {
+		if h.pattern == host {
+			return h.router
+		}
+	}
+	return nil
+}
+
+// hasPattern reports whether any router of t has a route with pattern.
+func (t *routeTable) hasPattern(pattern string) bool {
+	if t.router.hasPattern(pattern) {
+		return true
+	}
+	for _, h := range t.hosts {
+		if h.router.hasPattern(pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxParams returns the params capacity a request may need.
+func (t *routeTable) maxParams() int {
+	max := t.router.maxParams
+	for _, h := range t.hosts {
+		if n := len(h.keys) + h.router.maxParams; n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// addRoute registers the route on the router of host.
+func (e *Engine) addRoute(group *RouterGroup, method, pattern string, handlers []HandlerFunc) {
+	e.updateTable(func(t *routeTable) {
+		handlers = group.combineHandlers(handlers)
+		debugPrintRoute(method, pattern, handlers)
+		t.mutableRouter(group.host, method).addRouter(method, pattern, handlers)
+	})
+}
+
+// RemoveRoute unregisters the host-agnostic route of method and path, path
+// being the pattern the route was registered with, including the group
+// prefix. It reports whether such a route was registered. Requests already
+// being served keep running the removed handlers. Route names which refer to
+// the pattern are dropped once no route of another method or host has it.
+// The routes registered through Host are removed with RemoveHostRoute.
+// Like the registration of routes it panics after the server started, unless
+// DynamicRouting is enabled.
+func (e *Engine) RemoveRoute(method, path string) bool {
+	return e.removeRoute("", method, path)
+}
+
+// RemoveHostRoute is like RemoveRoute, but unregisters a route registered
+// through Host with the host pattern.
+func (e *Engine) RemoveHostRoute(host, method, path string) bool {
+	if host == "" {
+		return false
+	}
+	return e.removeRoute(strings.ToLower(host), method, path)
+}
+
+func (e *Engine) removeRoute(host, method, path string) bool {
+	removed := false
+	e.updateTable(func(t *routeTable) {
+		if r := t.routerOf(host); r == nil || r.roots[method] == nil {
+			return
+		}
+		router, hosts := t.router, t.hosts
+		removed = t.mutableRouter(host, method).removeRouter(method, path)
+		if !removed {
+			// keep the current snapshot instead of the copies
+			t.router, t.hosts = router, hosts
+			return
+		}
+
+		if !t.hasPattern(path) {
+			for name, pattern := range e.namedRoutes {
+				if pattern == path {
+					delete(e.namedRoutes, name)
+				}
+			}
+		}
+	})
+	if removed {
+		debugPrint("removed route %s %s%s", method, host, path)
+	}
+	return removed
+}
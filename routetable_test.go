@@ -0,0 +1,225 @@
+package gee
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineRemoveRoute(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	e.GET("/users/:id<int>", func(c *Context) {
+		c.String(http.StatusOK, "user %s", c.Param("id"))
+	})
+	e.GET("/users/:name", func(c *Context) {
+		c.String(http.StatusOK, "name %s", c.Param("name"))
+	})
+	e.POST("/users/:id<int>", func(c *Context) {})
+	api := e.Host("api.example.com")
+	api.GET("/users/:id<int>", func(c *Context) {
+		c.String(http.StatusOK, "api user")
+	})
+
+	assert.Equal("user 1", performRequest(e, "GET", "/users/1").Body.String())
+
+	assert.True(e.RemoveRoute("GET", "/users/:id<int>"))
+	assert.False(e.RemoveRoute("GET", "/users/:id<int>"))
+	assert.False(e.RemoveRoute("GET", "/users/:id"))
+	assert.False(e.RemoveRoute("DELETE", "/users/:id<int>"))
+
+	// the other routes of the path and the host routes are kept
+	assert.Equal("name 1", performRequest(e, "GET", "/users/1").Body.String())
+	assert.Equal(http.StatusOK, performRequest(e, "POST", "/users/1").Code)
+	assert.Equal("api user", performHostRequest(e, "GET", "api.example.com", "/users/1").Body.String())
+
+	assert.True(e.RemoveRoute("GET", "/users/:name"))
+	assert.Equal(http.StatusNotFound, performRequest(e, "GET", "/users/1").Code)
+
+	// the path can be registered again
+	e.GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "again %s", c.Param("id"))
+	})
+	assert.Equal("again 1", performRequest(e, "GET", "/users/1").Body.String())
+}
+
+func TestEngineFrozenRoutes(t *testing.T) {
+	e := New()
+	e.GET("/", func(c *Context) {})
+	e.freeze()
+
+	assert.Panics(t, func() { e.GET("/late", func(c *Context) {}) })
+	assert.Panics(t, func() { e.RemoveRoute("GET", "/") })
+	assert.Panics(t, func() { e.Use(func(c *Context) {}) })
+	assert.Equal(t, http.StatusOK, performRequest(e, "GET", "/").Code)
+
+	e.DynamicRouting = true
+	assert.NotPanics(t, func() { e.GET("/late", func(c *Context) {}) })
+	assert.True(t, e.RemoveRoute("GET", "/"))
+	assert.Equal(t, http.StatusOK, performRequest(e, "GET", "/late").Code)
+}
+
+// TestEngineDynamicRouting changes the routes while requests are served,
+// it is meant to be run with the race detector.
+func TestEngineDynamicRouting(t *testing.T) {
+	e := New()
+	e.DynamicRouting = true
+	e.GET("/static", func(c *Context) {
+		c.String(http.StatusOK, "static")
+	})
+	e.freeze()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				w := performRequest(e, "GET", "/static")
+				assert.Equal(t, "static", w.Body.String())
+				performRequest(e, "GET", "/plugins/1/x")
+				e.Routes()
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		path := fmt.Sprintf("/plugins/%d/:action", i)
+		e.GET(path, func(c *Context) {
+			c.String(http.StatusOK, c.Param("action"))
+		})
+		if i%2 == 1 {
+			assert.True(t, e.RemoveRoute("GET", path))
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	assert.Equal(t, 50+1, len(e.Routes()))
+	assert.Equal(t, "x", performRequest(e, "GET", "/plugins/0/x").Body.String())
+	assert.Equal(t, http.StatusNotFound, performRequest(e, "GET", "/plugins/1/x").Code)
+}
+
+func TestEngineCopyOnWrite(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	api := e.Host("api.example.com")
+
+	// before the start the routers are modified in place
+	r, hr := e.loadTable().router, e.loadTable().hosts[0].router
+	e.GET("/a", func(c *Context) {})
+	e.Any("/any", func(c *Context) {})
+	api.GET("/a", func(c *Context) {})
+	assert.True(r == e.loadTable().router)
+	assert.True(hr == e.loadTable().hosts[0].router)
+
+	// then each change copies the router, the old snapshot stays intact
+	e.freeze()
+	e.DynamicRouting = true
+	assertCopyOnWrite(t, e)
+
+	// with DynamicRouting also before the start,
+	// as the engine may be served without a Run function
+	e = New()
+	e.DynamicRouting = true
+	e.Host("api.example.com").GET("/a", func(c *Context) {})
+	e.GET("/a", func(c *Context) {})
+	assertCopyOnWrite(t, e)
+}
+
+// assertCopyOnWrite checks that the route changes of e keep the current
+// snapshot intact, e needs the route GET /a on the engine and its only host.
+func assertCopyOnWrite(t *testing.T, e *Engine) {
+	assert := assert.New(t)
+	api := &RouterGroup{parent: e.RouterGroup, engine: e, host: e.loadTable().hosts[0].pattern}
+
+	old := e.loadTable()
+	e.GET("/b", func(c *Context) {})
+	api.GET("/b", func(c *Context) {})
+	assert.True(e.RemoveRoute("GET", "/a"))
+	assert.False(old.router == e.loadTable().router)
+	assert.False(old.hosts[0].router == e.loadTable().hosts[0].router)
+
+	params := make(Params, 0, 1)
+	assert.NotNil(old.router.getRouter("GET", "/a", &params))
+	assert.Nil(old.router.getRouter("GET", "/b", &params))
+	assert.Nil(old.hosts[0].router.getRouter("GET", "/b", &params))
+	assert.Nil(e.loadTable().router.getRouter("GET", "/a", &params))
+	assert.NotNil(e.loadTable().router.getRouter("GET", "/b", &params))
+}
+
+// TestEngineDynamicRoutingUnstarted changes the routes of an engine served
+// without a Run function, it is meant to be run with the race detector.
+func TestEngineDynamicRoutingUnstarted(t *testing.T) {
+	e := New()
+	e.DynamicRouting = true
+	e.GET("/static", func(c *Context) {})
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			resp, err := http.Get(srv.URL + "/static")
+			if assert.Nil(t, err) {
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+			}
+		}
+	}()
+	plugin := e.Group("/plugin")
+	for i := 0; i < 50; i++ {
+		plugin.GET(fmt.Sprintf("/%d", i), func(c *Context) {})
+	}
+	<-done
+	assert.Equal(t, 51, len(e.Routes()))
+}
+
+func TestEngineRemoveRouteNames(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	e.GET("/users/:id", func(c *Context) {}).Name("user.show")
+	e.PUT("/users/:id", func(c *Context) {}).Name("user.update")
+	api := e.Host("API.example.com")
+	api.GET("/status", func(c *Context) {
+		c.String(http.StatusOK, "api")
+	}).Name("api.status")
+
+	// the name is kept while another method has the pattern
+	assert.True(e.RemoveRoute("GET", "/users/:id"))
+	url, err := e.URLFor("user.show", 1)
+	assert.Nil(err)
+	assert.Equal("/users/1", url)
+
+	assert.True(e.RemoveRoute("PUT", "/users/:id"))
+	_, err = e.URLFor("user.show", 1)
+	assert.NotNil(err)
+	_, err = e.URLFor("user.update", 1)
+	assert.NotNil(err)
+
+	// the name can be used again
+	assert.NotPanics(func() {
+		e.GET("/people/:id", func(c *Context) {}).Name("user.show")
+	})
+
+	// the host routes are only removed by RemoveHostRoute
+	assert.False(e.RemoveRoute("GET", "/status"))
+	assert.Equal("api", performHostRequest(e, "GET", "api.example.com", "/status").Body.String())
+	assert.False(e.RemoveHostRoute("other.example.com", "GET", "/status"))
+	assert.True(e.RemoveHostRoute("api.example.com", "GET", "/status"))
+	assert.False(e.RemoveHostRoute("api.example.com", "GET", "/status"))
+	assert.Equal(http.StatusNotFound, performHostRequest(e, "GET", "api.example.com", "/status").Code)
+	_, err = e.URLFor("api.status")
+	assert.NotNil(err)
+}
@@ -0,0 +1,164 @@
+package gee
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrCookieTampered is returned for a secure cookie value which was not
+	// created with any of the keys, or was modified.
+	ErrCookieTampered = errors.New("cookie value is invalid or was tampered with")
+	// ErrCookieExpired is returned for a secure cookie value that expired.
+	ErrCookieExpired = errors.New("cookie value expired")
+	// ErrNoSecureCookie is returned by the secure cookie methods of Context
+	// when no SecureCookie is configured on the engine.
+	ErrNoSecureCookie = errors.New("no SecureCookie configured on the engine")
+
+	errNoHashKey  = errors.New("no hash key to sign the cookie value")
+	errNoBlockKey = errors.New("no block key to encrypt the cookie value")
+)
+
+// SecureCookie signs cookie values with HMAC-SHA256 or encrypts them with
+// AES-GCM, in both cases the value is bound to the cookie name and may carry
+// an expiry time.
+//
+// The keys are rotated by putting a new key first: values are signed and
+// encrypted with the first key, but verified and decrypted with any of the
+// keys, so the old keys can be dropped once their cookies have expired.
+type SecureCookie struct {
+	hashKeys [][]byte
+	aeads    []cipher.AEAD
+
+	now func() time.Time
+}
+
+// NewSecureCookie returns a SecureCookie signing with the hashKeys and
+// encrypting with the blockKeys. A hash key should have 32 or 64 random
+// bytes, a block key must have 16, 24 or 32 bytes to select AES-128, AES-192
+// or AES-256. Either list may be empty if the cookies are only signed or only
+// encrypted.
+func NewSecureCookie(hashKeys, blockKeys [][]byte) (*SecureCookie, error) {
+	if len(hashKeys) == 0 && len(blockKeys) == 0 {
+		return nil, errors.New("no hash or block keys given")
+	}
+
+	s := &SecureCookie{now: time.Now}
+	for _, key := range hashKeys {
+		if len(key) == 0 {
+			return nil, errors.New("empty hash key")
+		}
+		s.hashKeys = append(s.hashKeys, key)
+	}
+	for _, key := range blockKeys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		s.aeads = append(s.aeads, aead)
+	}
+	return s, nil
+}
+
+// payload returns value prefixed with its expiry time in Unix seconds,
+// zero if it does not expire.
+func payload(value string, expires time.Time) []byte {
+	b := make([]byte, 8, 8+len(value))
+	if !expires.IsZero() {
+		binary.BigEndian.PutUint64(b, uint64(expires.Unix()))
+	}
+	return append(b, value...)
+}
+
+// parsePayload returns the value of a payload if it has not expired.
+func (s *SecureCookie) parsePayload(b []byte) (string, error) {
+	if len(b) < 8 {
+		return "", ErrCookieTampered
+	}
+	if expires := int64(binary.BigEndian.Uint64(b)); expires != 0 && s.now().Unix() >= expires {
+		return "", ErrCookieExpired
+	}
+	return string(b[8:]), nil
+}
+
+func (s *SecureCookie) mac(key []byte, name string, b []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+// Sign returns the signed form of the value of the cookie name, which expires
+// at the given time unless it is zero.
+func (s *SecureCookie) Sign(name, value string, expires time.Time) (string, error) {
+	if len(s.hashKeys) == 0 {
+		return "", errNoHashKey
+	}
+	b := payload(value, expires)
+	b = append(b, s.mac(s.hashKeys[0], name, b)...)
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Verify returns the value of the cookie name signed with Sign. It fails with
+// ErrCookieTampered if no key matches the signature and with
+// ErrCookieExpired if the value expired.
+func (s *SecureCookie) Verify(name, signed string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(signed)
+	if err != nil || len(b) < sha256.Size {
+		return "", ErrCookieTampered
+	}
+	b, sum := b[:len(b)-sha256.Size], b[len(b)-sha256.Size:]
+	for _, key := range s.hashKeys {
+		if hmac.Equal(sum, s.mac(key, name, b)) {
+			return s.parsePayload(b)
+		}
+	}
+	return "", ErrCookieTampered
+}
+
+// Encrypt returns the encrypted form of the value of the cookie name, which
+// expires at the given time unless it is zero.
+func (s *SecureCookie) Encrypt(name, value string, expires time.Time) (string, error) {
+	if len(s.aeads) == 0 {
+		return "", errNoBlockKey
+	}
+	aead := s.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	b := aead.Seal(nonce, nonce, payload(value, expires), []byte(name))
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Decrypt returns the value of the cookie name encrypted with Encrypt. It
+// fails with ErrCookieTampered if no key decrypts the value and with
+// ErrCookieExpired if the value expired.
+func (s *SecureCookie) Decrypt(name, encrypted string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", ErrCookieTampered
+	}
+	for _, aead := range s.aeads {
+		if len(b) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := b[:aead.NonceSize()], b[aead.NonceSize():]
+		if plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+			return s.parsePayload(plaintext)
+		}
+	}
+	return "", ErrCookieTampered
+}
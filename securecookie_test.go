@@ -0,0 +1,117 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSecureCookie(t *testing.T, hashKeys, blockKeys [][]byte) *SecureCookie {
+	s, err := NewSecureCookie(hashKeys, blockKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// tamper flips a bit of the decoded value.
+func tamper(encoded string) string {
+	b, _ := base64.RawURLEncoding.DecodeString(encoded)
+	b[len(b)/2] ^= 1
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestSecureCookieSign(t *testing.T) {
+	assert := assert.New(t)
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	s := newTestSecureCookie(t, [][]byte{oldKey}, nil)
+
+	signed, err := s.Sign("session", "user=42", time.Time{})
+	assert.Nil(err)
+	value, err := s.Verify("session", signed)
+	assert.Nil(err)
+	assert.Equal("user=42", value)
+
+	_, err = s.Verify("session", tamper(signed))
+	assert.Equal(ErrCookieTampered, err)
+	_, err = s.Verify("other", signed)
+	assert.Equal(ErrCookieTampered, err)
+	_, err = s.Verify("session", "not base64!")
+	assert.Equal(ErrCookieTampered, err)
+	_, err = s.Verify("session", "")
+	assert.Equal(ErrCookieTampered, err)
+
+	// the values of the old key are accepted after a rotation
+	rotated := newTestSecureCookie(t, [][]byte{newKey, oldKey}, nil)
+	value, err = rotated.Verify("session", signed)
+	assert.Nil(err)
+	assert.Equal("user=42", value)
+	newSigned, err := rotated.Sign("session", "user=42", time.Time{})
+	assert.Nil(err)
+	_, err = s.Verify("session", newSigned)
+	assert.Equal(ErrCookieTampered, err)
+
+	_, err = s.Encrypt("session", "user=42", time.Time{})
+	assert.NotNil(err)
+}
+
+func TestSecureCookieEncrypt(t *testing.T) {
+	assert := assert.New(t)
+	oldKey, newKey := bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{2}, 32)
+	s := newTestSecureCookie(t, nil, [][]byte{oldKey})
+
+	encrypted, err := s.Encrypt("session", "user=42", time.Time{})
+	assert.Nil(err)
+	assert.NotContains(encrypted, "user")
+	value, err := s.Decrypt("session", encrypted)
+	assert.Nil(err)
+	assert.Equal("user=42", value)
+
+	_, err = s.Decrypt("session", tamper(encrypted))
+	assert.Equal(ErrCookieTampered, err)
+	_, err = s.Decrypt("other", encrypted)
+	assert.Equal(ErrCookieTampered, err)
+	_, err = s.Decrypt("session", "AAAA")
+	assert.Equal(ErrCookieTampered, err)
+
+	rotated := newTestSecureCookie(t, nil, [][]byte{newKey, oldKey})
+	value, err = rotated.Decrypt("session", encrypted)
+	assert.Nil(err)
+	assert.Equal("user=42", value)
+
+	_, err = s.Sign("session", "user=42", time.Time{})
+	assert.NotNil(err)
+}
+
+func TestSecureCookieExpiry(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestSecureCookie(t, [][]byte{[]byte("hash key")}, [][]byte{bytes.Repeat([]byte{1}, 32)})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	expires := now.Add(time.Hour)
+	signed, _ := s.Sign("session", "user=42", expires)
+	encrypted, _ := s.Encrypt("session", "user=42", expires)
+	_, err := s.Verify("session", signed)
+	assert.Nil(err)
+	_, err = s.Decrypt("session", encrypted)
+	assert.Nil(err)
+
+	now = now.Add(2 * time.Hour)
+	_, err = s.Verify("session", signed)
+	assert.Equal(ErrCookieExpired, err)
+	_, err = s.Decrypt("session", encrypted)
+	assert.Equal(ErrCookieExpired, err)
+}
+
+func TestNewSecureCookie(t *testing.T) {
+	_, err := NewSecureCookie(nil, nil)
+	assert.NotNil(t, err)
+	_, err = NewSecureCookie([][]byte{{}}, nil)
+	assert.NotNil(t, err)
+	_, err = NewSecureCookie(nil, [][]byte{[]byte("short")})
+	assert.NotNil(t, err)
+}
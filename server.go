@@ -0,0 +1,230 @@
+package gee
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ServerConfig holds the settings of the http.Server used by the Run
+// functions, the zero values keep the defaults of net/http.
+type ServerConfig struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+}
+
+// Run starts a server listening on addr and serves the engine until it fails
+// or Shutdown is called. It returns nil after a Shutdown.
+func (e *Engine) Run(addr string) error {
+	return e.RunContext(context.Background(), addr)
+}
+
+// RunContext is like Run, but also shuts its server down gracefully when ctx
+// is done, within ShutdownTimeout. The other servers of the engine keep
+// serving and the shutdown hooks do not run, they are left to Shutdown.
+// It returns after the shutdown completed.
+func (e *Engine) RunContext(ctx context.Context, addr string) error {
+	return e.runServer(ctx, e.newServer(addr), (*http.Server).ListenAndServe)
+}
+
+// RunTLS is like Run, but serves HTTPS with the certificate and the matching
+// private key in the given files.
+func (e *Engine) RunTLS(addr, certFile, keyFile string) error {
+	return e.runServer(context.Background(), e.newServer(addr), func(srv *http.Server) error {
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	})
+}
+
+// RunUnix is like Run, but listens on the Unix socket at path. A stale socket
+// file left at path by a previous server is removed, other files are not
+// touched. The socket file is removed when the server stops.
+func (e *Engine) RunUnix(path string) error {
+	removeStaleSocket(path)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	return e.RunListener(l)
+}
+
+// removeStaleSocket removes the socket file at path if no server accepts
+// connections on it anymore.
+func removeStaleSocket(path string) {
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode()&fs.ModeSocket == 0 {
+		return
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		// the socket is in use
+		conn.Close()
+		return
+	}
+	os.Remove(path)
+}
+
+// RunFd is like Run, but serves the listener of the file descriptor fd,
+// like a socket passed by systemd socket activation.
+func (e *Engine) RunFd(fd int) error {
+	f := os.NewFile(uintptr(fd), "fd@"+strconv.Itoa(fd))
+	if f == nil {
+		return fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return err
+	}
+	return e.RunListener(l)
+}
+
+// RunListener is like Run, but accepts the connections of l.
+// The listener is closed when the server stops.
+func (e *Engine) RunListener(l net.Listener) error {
+	return e.runServer(context.Background(), e.newServer(l.Addr().String()), func(srv *http.Server) error {
+		return srv.Serve(l)
+	})
+}
+
+// newServer returns a server for the engine and registers it for Shutdown.
+// The routes are frozen from then on, see DynamicRouting.
+func (e *Engine) newServer(addr string) *http.Server {
+	e.freeze()
+	e.debugPrintSettingWarnings()
+	debugPrint("Listening and serving HTTP on %s", addr)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           e,
+		ReadTimeout:       e.ServerConfig.ReadTimeout,
+		ReadHeaderTimeout: e.ServerConfig.ReadHeaderTimeout,
+		WriteTimeout:      e.ServerConfig.WriteTimeout,
+		IdleTimeout:       e.ServerConfig.IdleTimeout,
+		MaxHeaderBytes:    e.ServerConfig.MaxHeaderBytes,
+	}
+	e.mu.Lock()
+	e.servers = append(e.servers, srv)
+	e.mu.Unlock()
+	return srv
+}
+
+// debugPrintSettingWarnings warns about the settings that should not be used
+// in production.
+func (e *Engine) debugPrintSettingWarnings() {
+	debugPrintWarning("Running in %q mode. Switch to %q mode in production:\n"+
+		"  - using env:\texport %s=%s\n"+
+		"  - using code:\tgee.SetMode(gee.ReleaseMode)", DebugMode, ReleaseMode, EnvGeeMode, ReleaseMode)
+
+	for _, cidr := range e.trustedCIDRs {
+		if ones, _ := cidr.Mask.Size(); ones == 0 {
+			debugPrintWarning("All proxies are trusted (%s), so clients can spoof the IP reported by ClientIP.\n"+
+				"Trust only the IPs of your proxies with Engine.SetTrustedProxies.", cidr)
+			break
+		}
+	}
+	if e.DynamicRouting {
+		debugPrintWarning("DynamicRouting is enabled, routes registered by mistake after the start are not detected.")
+	}
+}
+
+// runServer runs serve with srv until it fails, or shuts srv down when ctx
+// is done.
+func (e *Engine) runServer(ctx context.Context, srv *http.Server, serve func(*http.Server) error) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- serve(srv)
+	}()
+
+	var err error
+	select {
+	case err = <-errc:
+		e.removeServer(srv)
+	case <-ctx.Done():
+		err = e.shutdownServer(srv)
+		<-errc
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// shutdownServer gracefully shuts down srv alone, like Shutdown does with
+// all the servers but without running the hooks.
+func (e *Engine) shutdownServer(srv *http.Server) error {
+	e.removeServer(srv)
+	ctx := context.Background()
+	if e.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.ShutdownTimeout)
+		defer cancel()
+	}
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		srv.Close()
+	}
+	return err
+}
+
+func (e *Engine) removeServer(srv *http.Server) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, s := range e.servers {
+		if s == srv {
+			e.servers = append(e.servers[:i:i], e.servers[i+1:]...)
+			return
+		}
+	}
+}
+
+// OnShutdown registers a hook which is run by Shutdown, like flushing a
+// logger or closing a database pool. The hooks run in the order they were
+// registered.
+func (e *Engine) OnShutdown(hook func(ctx context.Context) error) {
+	e.mu.Lock()
+	e.shutdownHooks = append(e.shutdownHooks, hook)
+	e.mu.Unlock()
+}
+
+// Shutdown gracefully shuts down the servers started by the Run functions.
+// They stop accepting connections, then Shutdown waits for the active requests
+// to finish until ctx is done or ShutdownTimeout passed, after which the
+// remaining connections are closed. Then the shutdown hooks run with ctx, also
+// if draining failed. Each hook runs once, even if Shutdown is called again.
+// Shutdown returns the first error of draining or of the hooks.
+func (e *Engine) Shutdown(ctx context.Context) error {
+	if e.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.ShutdownTimeout)
+		defer cancel()
+	}
+
+	e.mu.Lock()
+	servers, hooks := e.servers, e.shutdownHooks
+	e.servers, e.shutdownHooks = nil, nil
+	e.mu.Unlock()
+
+	var firstErr error
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			srv.Close()
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	for _, hook := range hooks {
+		if err := hook(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
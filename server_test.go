@@ -0,0 +1,331 @@
+package gee
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// freeAddr returns a local address nothing listens on.
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// waitServing waits until the server at addr accepts connections.
+func waitServing(t *testing.T, addr string) {
+	for i := 0; i < 100; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("server did not start")
+}
+
+func TestEngineRunContext(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	started := make(chan struct{})
+	e.GET("/slow", func(c *Context) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+	var hooks []string
+	e.OnShutdown(func(ctx context.Context) error {
+		hooks = append(hooks, "logger")
+		return nil
+	})
+	e.OnShutdown(func(ctx context.Context) error {
+		hooks = append(hooks, "db")
+		return nil
+	})
+
+	addr := freeAddr(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- e.RunContext(ctx, addr)
+	}()
+	waitServing(t, addr)
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+
+	// the request in flight is completed before RunContext returns
+	<-started
+	cancel()
+	assert.Nil(<-runErr)
+	assert.Equal("done", <-body)
+
+	_, err := net.Dial("tcp", addr)
+	assert.NotNil(err)
+
+	// the hooks are left to Shutdown, they run once
+	assert.Nil(hooks)
+	assert.Nil(e.Shutdown(context.Background()))
+	assert.Equal([]string{"logger", "db"}, hooks)
+	assert.Nil(e.Shutdown(context.Background()))
+	assert.Equal([]string{"logger", "db"}, hooks)
+}
+
+func TestEngineRunContextTwoServers(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	e.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	hookRan := false
+	e.OnShutdown(func(ctx context.Context) error {
+		hookRan = true
+		return nil
+	})
+
+	addr1, addr2 := freeAddr(t), freeAddr(t)
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+	runErr1, runErr2 := make(chan error, 1), make(chan error, 1)
+	go func() {
+		runErr1 <- e.RunContext(ctx1, addr1)
+	}()
+	go func() {
+		runErr2 <- e.RunContext(context.Background(), addr2)
+	}()
+	waitServing(t, addr1)
+	waitServing(t, addr2)
+
+	// cancelling the first server leaves the second one serving
+	cancel1()
+	assert.Nil(<-runErr1)
+	_, err := net.Dial("tcp", addr1)
+	assert.NotNil(err)
+	resp, err := http.Get("http://" + addr2 + "/")
+	if assert.Nil(err) {
+		resp.Body.Close()
+		assert.Equal(http.StatusOK, resp.StatusCode)
+	}
+	assert.False(hookRan)
+
+	assert.Nil(e.Shutdown(context.Background()))
+	assert.Nil(<-runErr2)
+	assert.True(hookRan)
+}
+
+func TestEngineShutdownTimeout(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	e.ShutdownTimeout = 50 * time.Millisecond
+	started := make(chan struct{})
+	release := make(chan struct{})
+	e.GET("/blocked", func(c *Context) {
+		close(started)
+		<-release
+	})
+	hookErr := errors.New("flush failed")
+	hookRan := false
+	e.OnShutdown(func(ctx context.Context) error {
+		hookRan = true
+		return hookErr
+	})
+
+	addr := freeAddr(t)
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- e.Run(addr)
+	}()
+	waitServing(t, addr)
+	go http.Get("http://" + addr + "/blocked")
+	<-started
+
+	// draining times out, the hooks run anyway
+	err := e.Shutdown(context.Background())
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.True(hookRan)
+	assert.Nil(<-runErr)
+	close(release)
+
+	e = New()
+	e.OnShutdown(func(ctx context.Context) error {
+		return hookErr
+	})
+	assert.Equal(hookErr, e.Shutdown(context.Background()))
+}
+
+// serveUntilShutdown runs run in the background, calls check once the
+// server is registered and shuts it down afterwards.
+func serveUntilShutdown(t *testing.T, e *Engine, run func() error, check func()) {
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- run()
+	}()
+	for i := 0; ; i++ {
+		e.mu.RLock()
+		n := len(e.servers)
+		e.mu.RUnlock()
+		if n > 0 {
+			break
+		}
+		if i == 100 {
+			t.Fatal("server did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	check()
+	assert.Nil(t, e.Shutdown(context.Background()))
+	assert.Nil(t, <-runErr)
+}
+
+func TestEngineRunListener(t *testing.T) {
+	e := New()
+	e.ServerConfig = ServerConfig{
+		ReadHeaderTimeout: time.Second,
+		IdleTimeout:       time.Minute,
+		MaxHeaderBytes:    1 << 10,
+	}
+	e.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "listener")
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveUntilShutdown(t, e, func() error { return e.RunListener(l) }, func() {
+		srv := e.servers[0]
+		assert.Equal(t, time.Second, srv.ReadHeaderTimeout)
+		assert.Equal(t, time.Minute, srv.IdleTimeout)
+		assert.Equal(t, 1<<10, srv.MaxHeaderBytes)
+
+		resp, err := http.Get("http://" + l.Addr().String() + "/")
+		if assert.Nil(t, err) {
+			b, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, "listener", string(b))
+		}
+	})
+}
+
+func TestEngineRunUnix(t *testing.T) {
+	e := New()
+	e.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "unix")
+	})
+	path := filepath.Join(t.TempDir(), "gee.sock")
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}}
+
+	serveUntilShutdown(t, e, func() error { return e.RunUnix(path) }, func() {
+		resp, err := client.Get("http://unix/")
+		if assert.Nil(t, err) {
+			b, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, "unix", string(b))
+		}
+	})
+
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestEngineRunUnixStaleSocket(t *testing.T) {
+	assert := assert.New(t)
+	e := New()
+	e.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "unix")
+	})
+	dir := t.TempDir()
+
+	// a socket file left behind by a crashed server is replaced
+	path := filepath.Join(dir, "stale.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	serveUntilShutdown(t, e, func() error { return e.RunUnix(path) }, func() {
+		conn, err := net.Dial("unix", path)
+		if assert.Nil(err) {
+			conn.Close()
+		}
+	})
+
+	// other files are not touched
+	path = filepath.Join(dir, "data.txt")
+	assert.Nil(os.WriteFile(path, []byte("data"), 0o600))
+	assert.NotNil(e.RunUnix(path))
+	b, err := os.ReadFile(path)
+	assert.Nil(err)
+	assert.Equal("data", string(b))
+
+	// neither are the sockets in use
+	path = filepath.Join(dir, "live.sock")
+	live, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+	assert.NotNil(e.RunUnix(path))
+	go func() {
+		if conn, err := live.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("unix", path)
+	if assert.Nil(err) {
+		conn.Close()
+	}
+}
+
+func TestEngineRunFd(t *testing.T) {
+	e := New()
+	e.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "fd")
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	serveUntilShutdown(t, e, func() error { return e.RunFd(int(f.Fd())) }, func() {
+		resp, err := http.Get("http://" + l.Addr().String() + "/")
+		if assert.Nil(t, err) {
+			b, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, "fd", string(b))
+		}
+	})
+	assert.NotNil(t, e.RunFd(-1))
+}
@@ -1,67 +1,588 @@
 package gee
 
-import "strings"
+import (
+	"regexp"
+	"strings"
+)
 
-// the node of router tree
+// Param is a single URL parameter, consisting of a key and a value.
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params is a Param-slice, as filled by the router tree.
+// The slice is ordered, the first URL parameter is also the first slice value.
+type Params []Param
+
+// Get returns the value of the first Param which key matches the given name
+// and a boolean true. If no matching Param is found, an empty string is
+// returned and a boolean false.
+func (ps Params) Get(name string) (string, bool) {
+	for _, entry := range ps {
+		if entry.Key == name {
+			return entry.Value, true
+		}
+	}
+	return "", false
+}
+
+// ByName returns the value of the first Param which key matches the given name.
+// If no matching Param is found, an empty string is returned.
+func (ps Params) ByName(name string) (va string) {
+	va, _ = ps.Get(name)
+	return
+}
+
+// the node of router radix tree.
+//
+// Static path fragments are compressed into edges: a node holds the part of
+// the path it adds to its parent, and siblings never share a first byte.
+// A wildcard always takes a whole path segment, so ":name" and "*name"
+// children only hang off nodes which end with a '/'.
 type node struct {
+	// path is the edge label for a static node, ":name" or ":name<constraint>"
+	// for a param node and "*name" for a catch-all node.
+	path string
+
+	// indices holds the first byte of every static child,
+	// in the same order as children.
+	indices  string
+	children []*node // static children, ordered by priority
+
+	// the ":name" children, tried after the static children. The children
+	// with a constraint come first, the one without is tried last.
+	wildChildren []*node
+	catchAll     *node // the "*name" child, it excludes all other children
+
+	// for param nodes, the name of the param and the constraint
+	// the segment must satisfy, if any
+	key   string
+	match func(string) bool
+
+	// priority is the number of routes registered in the subtree,
+	// busier subtrees are tried first.
+	priority uint32
+
+	// set when the node terminates a registered route
 	pattern  string
-	part     string
-	children []*node
-	isWild   bool
+	handlers []HandlerFunc
 }
 
-// the first matching node, used in inserting
-func (n *node) matchChild(part string) *node {
-	for _, child := range n.children {
-		if child.part == part || child.isWild {
+func longestCommonPrefix(a, b string) int {
+	i := 0
+	max := len(a)
+	if len(b) < max {
+		max = len(b)
+	}
+	for i < max && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// wildcardIndex returns the index of the first ':' or '*' which starts a path
+// segment, or -1 if the path is fully static.
+func wildcardIndex(path string) int {
+	for i := 1; i < len(path); i++ {
+		if path[i-1] == '/' && (path[i] == ':' || path[i] == '*') {
+			return i
+		}
+	}
+	return -1
+}
+
+// segmentAt returns the path segment which contains path[i].
+func segmentAt(path string, i int) string {
+	start := strings.LastIndexByte(path[:i], '/') + 1
+	end := strings.IndexByte(path[i:], '/')
+	if end < 0 {
+		return path[start:]
+	}
+	return path[start : i+end]
+}
+
+// wildcardEnd returns the end of the wildcard which starts at path[i]. It is
+// the end of the segment, unless the wildcard has a constraint, which may
+// contain slashes and ends with a '>' at the end of a segment.
+func wildcardEnd(path string, i int) int {
+	for j := i; j < len(path); j++ {
+		switch path[j] {
+		case '/':
+			return j
+		case '<':
+			for k := j; k < len(path); k++ {
+				if path[k] == '>' && (k+1 == len(path) || path[k+1] == '/') {
+					return k + 1
+				}
+			}
+			return len(path)
+		}
+	}
+	return len(path)
+}
+
+// splitParam splits a param wildcard into its name and its constraint,
+// ":id<int>" gives "id" and "int".
+func splitParam(wildcard string) (key, constraint string) {
+	key = wildcard[1:]
+	if i := strings.IndexByte(key, '<'); i >= 0 {
+		key, constraint = key[:i], strings.TrimSuffix(key[i+1:], ">")
+	}
+	return
+}
+
+// paramConstraints are the named param constraints,
+// any other constraint is a regular expression.
+var paramConstraints = map[string]func(string) bool{
+	"int":  isInt,
+	"uuid": isUUID,
+}
+
+// compileConstraint returns the function matching the segments which satisfy
+// the constraint. A regular expression has to match the whole segment.
+func compileConstraint(constraint string) (func(string) bool, error) {
+	if match, ok := paramConstraints[constraint]; ok {
+		return match, nil
+	}
+	re, err := regexp.Compile("^(?:" + constraint + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString, nil
+}
+
+func isInt(s string) bool {
+	if s != "" && s[0] == '-' {
+		s = s[1:]
+	}
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isUUID(s string) bool {
+	_, err := parseUUID(s)
+	return err == nil
+}
+
+// countParams returns the number of wildcards in the pattern.
+func countParams(pattern string) int {
+	return strings.Count(pattern, "/:") + strings.Count(pattern, "/*")
+}
+
+// incrementChildPrio increments the priority of the static child at pos and
+// moves it forward so that children stay sorted by priority.
+// It returns the new position of the child.
+func (n *node) incrementChildPrio(pos int) int {
+	cs := n.children
+	cs[pos].priority++
+	prio := cs[pos].priority
+
+	newPos := pos
+	for ; newPos > 0 && cs[newPos-1].priority < prio; newPos-- {
+		cs[newPos-1], cs[newPos] = cs[newPos], cs[newPos-1]
+	}
+
+	if newPos != pos {
+		n.indices = n.indices[:newPos] + // unchanged prefix
+			n.indices[pos:pos+1] + // the moved index char
+			n.indices[newPos:pos] + n.indices[pos+1:] // rest without the moved char
+	}
+	return newPos
+}
+
+// insertStatic walks down the static children of n along path, splitting
+// edges where needed, and returns the node the path ends at.
+// The full pattern is only used for the panic messages.
+func (n *node) insertStatic(path, pattern string) *node {
+	for path != "" {
+		i := strings.IndexByte(n.indices, path[0])
+		if i < 0 {
+			if n.catchAll != nil {
+				panic("'" + path + "' in new path '" + pattern +
+					"' conflicts with existing catch-all '" + n.catchAll.path + "'")
+			}
+			n.indices += string(path[0])
+			n.children = append(n.children, &node{path: path})
+			i = n.incrementChildPrio(len(n.children) - 1)
+			return n.children[i]
+		}
+
+		child := n.children[i]
+		l := longestCommonPrefix(path, child.path)
+		if l < len(child.path) {
+			// split the edge, the child keeps the common prefix
+			// and everything else moves into a new node below it.
+			rest := *child
+			rest.path = child.path[l:]
+			*child = node{
+				path:     child.path[:l],
+				indices:  string(rest.path[0]),
+				children: []*node{&rest},
+				priority: rest.priority,
+			}
+		}
+		n.incrementChildPrio(i)
+
+		path = path[l:]
+		n = child
+	}
+	return n
+}
+
+// insertParam returns the param child of n for the wildcard, it is created
+// if no child has the same constraint yet.
+func (n *node) insertParam(wildcard, key, constraint, pattern string) *node {
+	for _, child := range n.wildChildren {
+		if _, c := splitParam(child.path); c == constraint {
+			if child.key != key {
+				panic("'" + wildcard + "' in new path '" + pattern +
+					"' conflicts with existing wildcard '" + child.path + "'")
+			}
 			return child
 		}
 	}
+
+	child := &node{path: wildcard, key: key}
+	if constraint == "" {
+		n.wildChildren = append(n.wildChildren, child)
+		return child
+	}
+
+	match, err := compileConstraint(constraint)
+	if err != nil {
+		panic("invalid constraint in wildcard '" + wildcard + "' in path '" + pattern + "': " + err.Error())
+	}
+	child.match = match
+	i := len(n.wildChildren)
+	if i > 0 && n.wildChildren[i-1].match == nil {
+		// keep the unconstrained child last
+		i--
+	}
+	n.wildChildren = append(n.wildChildren[:i], append([]*node{child}, n.wildChildren[i:]...)...)
+	return child
+}
+
+// insert adds the route pattern with its handler chain to the tree.
+// It panics if the pattern is malformed or conflicts with an existing route.
+// Not concurrency-safe!
+func (n *node) insert(pattern string, handlers []HandlerFunc) {
+	n.priority++
+
+	path := pattern
+	for path != "" {
+		i := wildcardIndex(path)
+		static := path
+		if i >= 0 {
+			static = path[:i]
+		}
+		if j := strings.IndexAny(static, ":*"); j >= 0 {
+			panic("wildcards must start a path segment, has: '" +
+				segmentAt(path, j) + "' in path '" + pattern + "'")
+		}
+		if i < 0 {
+			n = n.insertStatic(path, pattern)
+			break
+		}
+		n = n.insertStatic(static, pattern)
+
+		end := wildcardEnd(path, i)
+		wildcard := path[i:end]
+		key, constraint := splitParam(wildcard)
+		if strings.ContainsAny(key, ":*") {
+			panic("only one wildcard per path segment is allowed, has: '" +
+				wildcard + "' in path '" + pattern + "'")
+		}
+
+		if wildcard[0] == '*' {
+			if end != len(path) {
+				panic("catch-all routes are only allowed at the end of the path in path '" + pattern + "'")
+			}
+			if len(n.children) > 0 {
+				panic("catch-all '" + wildcard + "' in new path '" + pattern +
+					"' conflicts with existing static children")
+			}
+			if len(n.wildChildren) > 0 {
+				panic("catch-all '" + wildcard + "' in new path '" + pattern +
+					"' conflicts with existing wildcard '" + n.wildChildren[0].path + "'")
+			}
+			if n.catchAll == nil {
+				n.catchAll = &node{path: wildcard}
+			} else if n.catchAll.path != wildcard {
+				panic("'" + wildcard + "' in new path '" + pattern +
+					"' conflicts with existing wildcard '" + n.catchAll.path + "'")
+			}
+			n = n.catchAll
+			n.priority++
+			break
+		}
+
+		if key == "" {
+			panic("wildcards must be named with a non-empty name in path '" + pattern + "'")
+		}
+		if n.catchAll != nil {
+			panic("'" + wildcard + "' in new path '" + pattern +
+				"' conflicts with existing catch-all '" + n.catchAll.path + "'")
+		}
+		n = n.insertParam(wildcard, key, constraint, pattern)
+		n.priority++
+		path = path[end:]
+	}
+
+	if n.pattern != "" {
+		panic("handlers are already registered for path '" + pattern + "'")
+	}
+	n.pattern = pattern
+	n.handlers = handlers
+}
+
+// search returns the node registered for path, n.path is expected to be
+// consumed already. The values of the wildcards are appended to params, which
+// must have enough capacity for the lookup to be allocation free.
+// Static children are tried first, then the param children and finally the
+// catch-all, backtracking whenever a branch does not lead to a route.
+func (n *node) search(path string, params *Params) *node {
+	if path == "" {
+		if n.pattern != "" {
+			return n
+		}
+	} else {
+		c := path[0]
+		for i := 0; i < len(n.indices); i++ {
+			if n.indices[i] == c {
+				child := n.children[i]
+				if len(path) >= len(child.path) && path[:len(child.path)] == child.path {
+					if result := child.search(path[len(child.path):], params); result != nil {
+						return result
+					}
+				}
+				break
+			}
+		}
+
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		// a param never matches an empty segment
+		if end > 0 {
+			value := path[:end]
+			for _, child := range n.wildChildren {
+				if child.match != nil && !child.match(value) {
+					continue
+				}
+				i := len(*params)
+				*params = append(*params, Param{Key: child.key, Value: value})
+				if result := child.search(path[end:], params); result != nil {
+					return result
+				}
+				*params = (*params)[:i]
+			}
+		}
+	}
+
+	if child := n.catchAll; child != nil && child.pattern != "" {
+		if len(child.path) > 1 {
+			*params = append(*params, Param{Key: child.path[1:], Value: path})
+		}
+		return child
+	}
 	return nil
 }
 
-// the all matching nodes, used in finding
-func (n *node) matchChildren(part string) []*node {
-	nodes := make([]*node, 0)
+// iterate appends the routes of the subtree to routes.
+func (n *node) iterate(method string, routes []RouteInfo) []RouteInfo {
+	if n.pattern != "" {
+		handler := lastHandler(n.handlers)
+		routes = append(routes, RouteInfo{
+			Method:      method,
+			Path:        n.pattern,
+			Handler:     nameOfFunction(handler),
+			HandlerFunc: handler,
+			NumHandlers: len(n.handlers),
+		})
+	}
 	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			nodes = append(nodes, child)
+		routes = child.iterate(method, routes)
+	}
+	for _, child := range n.wildChildren {
+		routes = child.iterate(method, routes)
+	}
+	if n.catchAll != nil {
+		routes = n.catchAll.iterate(method, routes)
+	}
+	return routes
+}
+
+// findCaseInsensitivePath makes a case-insensitive lookup of path and returns
+// it in the case of the registered route. If fixTrailingSlash is set, a
+// missing trailing slash is added or a superfluous one removed as well.
+func (n *node) findCaseInsensitivePath(path string, fixTrailingSlash bool) (string, bool) {
+	ciPath, ok := n.findCaseInsensitivePathRec(path, make([]byte, 0, len(path)+1), fixTrailingSlash)
+	return string(ciPath), ok
+}
+
+// findCaseInsensitivePathRec is the recursive helper of findCaseInsensitivePath,
+// the found path of the subtree is appended to ciPath.
+func (n *node) findCaseInsensitivePathRec(path string, ciPath []byte, fixTrailingSlash bool) ([]byte, bool) {
+	if path == "" && n.pattern != "" {
+		return ciPath, true
+	}
+
+	for _, child := range n.children {
+		if len(path) >= len(child.path) {
+			if strings.EqualFold(path[:len(child.path)], child.path) {
+				if result, ok := child.findCaseInsensitivePathRec(path[len(child.path):],
+					append(ciPath, child.path...), fixTrailingSlash); ok {
+					return result, true
+				}
+			}
+		} else if fixTrailingSlash && len(path)+1 == len(child.path) && child.path[len(path)] == '/' &&
+			child.pattern != "" && strings.EqualFold(path, child.path[:len(path)]) {
+			// the route only exists with a trailing slash
+			return append(ciPath, child.path...), true
+		}
+	}
+
+	end := strings.IndexByte(path, '/')
+	if end < 0 {
+		end = len(path)
+	}
+	if end > 0 {
+		value := path[:end]
+		for _, child := range n.wildChildren {
+			if child.match != nil && !child.match(value) {
+				continue
+			}
+			if result, ok := child.findCaseInsensitivePathRec(path[end:],
+				append(ciPath, value...), fixTrailingSlash); ok {
+				return result, true
+			}
+		}
+	}
+
+	if child := n.catchAll; child != nil && child.pattern != "" {
+		return append(ciPath, path...), true
+	}
+	if fixTrailingSlash && path == "/" && n.pattern != "" {
+		return ciPath, true
+	}
+	return nil, false
+}
+
+// clone returns a deep copy of the subtree, the handler chains are shared.
+func (n *node) clone() *node {
+	c := *n
+	if n.children != nil {
+		c.children = make([]*node, len(n.children))
+		for i, child := range n.children {
+			c.children[i] = child.clone()
+		}
+	}
+	if n.wildChildren != nil {
+		c.wildChildren = make([]*node, len(n.wildChildren))
+		for i, child := range n.wildChildren {
+			c.wildChildren[i] = child.clone()
 		}
 	}
-	return nodes
+	if n.catchAll != nil {
+		c.catchAll = n.catchAll.clone()
+	}
+	return &c
 }
 
-// insert node
-func (n *node) insert(pattern string, parts []string, height int) {
-	if len(parts) == height {
-		n.pattern = pattern
-		return
+func (n *node) isEmpty() bool {
+	return n.pattern == "" && len(n.children) == 0 && len(n.wildChildren) == 0 && n.catchAll == nil
+}
+
+// decrementChildPrio decrements the priority of the static child at pos and
+// moves it backward so that children stay sorted by priority.
+func (n *node) decrementChildPrio(pos int) {
+	cs := n.children
+	cs[pos].priority--
+	prio := cs[pos].priority
+
+	newPos := pos
+	for ; newPos < len(cs)-1 && cs[newPos+1].priority > prio; newPos++ {
+		cs[newPos+1], cs[newPos] = cs[newPos], cs[newPos+1]
 	}
-	part := parts[height]
-	child := n.matchChild(part)
-	if child == nil {
-		child = &node{part: part, isWild: part[0] == ':' || part[0] == '*'}
-		n.children = append(n.children, child)
+
+	if newPos != pos {
+		n.indices = n.indices[:pos] + n.indices[pos+1:newPos+1] + // the chars moved forward
+			n.indices[pos:pos+1] + // the moved index char
+			n.indices[newPos+1:] // unchanged suffix
 	}
-	child.insert(pattern, parts, height+1)
 }
 
-// search node
-func (n *node) search(parts []string, height int) *node {
-	if len(parts) == height || strings.HasPrefix(n.part, "*") {
+// remove unregisters the route pattern, pattern[pos:] is the part of it
+// below n. It reports whether the route was registered. Nodes left without
+// routes are pruned, the edges are not merged again.
+// Not concurrency-safe!
+func (n *node) remove(pattern string, pos int) bool {
+	path := pattern[pos:]
+	if path == "" {
 		if n.pattern == "" {
-			return nil
+			return false
 		}
-		return n
+		n.pattern = ""
+		n.handlers = nil
+		n.priority--
+		return true
 	}
-	part := parts[height]
-	children := n.matchChildren(part)
 
-	for _, child := range children {
-		result := child.search(parts, height+1)
-		if result != nil {
-			return result
+	if pos > 0 && pattern[pos-1] == '/' && (path[0] == ':' || path[0] == '*') {
+		end := wildcardEnd(pattern, pos)
+		wildcard := pattern[pos:end]
+
+		if path[0] == '*' {
+			if n.catchAll == nil || n.catchAll.path != wildcard || !n.catchAll.remove(pattern, end) {
+				return false
+			}
+			n.catchAll = nil
+			n.priority--
+			return true
+		}
+
+		for i, child := range n.wildChildren {
+			if child.path != wildcard {
+				continue
+			}
+			if !child.remove(pattern, end) {
+				return false
+			}
+			if child.isEmpty() {
+				n.wildChildren = append(n.wildChildren[:i:i], n.wildChildren[i+1:]...)
+			}
+			n.priority--
+			return true
 		}
+		return false
 	}
-	return nil
+
+	i := strings.IndexByte(n.indices, path[0])
+	if i < 0 {
+		return false
+	}
+	child := n.children[i]
+	if !strings.HasPrefix(path, child.path) || !child.remove(pattern, pos+len(child.path)) {
+		return false
+	}
+	if child.isEmpty() {
+		n.children = append(n.children[:i:i], n.children[i+1:]...)
+		n.indices = n.indices[:i] + n.indices[i+1:]
+	} else {
+		// the child's priority was decremented by its own remove, restore
+		// it here so that decrementChildPrio reorders the children
+		child.priority++
+		n.decrementChildPrio(i)
+	}
+	n.priority--
+	return true
 }
@@ -0,0 +1,568 @@
+package gee
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRoute struct {
+	method string
+	path   string
+}
+
+// githubAPI is the route set of the GitHub API v3.
+var githubAPI = []testRoute{
+	// OAuth Authorizations
+	{"GET", "/authorizations"},
+	{"GET", "/authorizations/:id"},
+	{"POST", "/authorizations"},
+	{"PUT", "/authorizations/clients/:client_id"},
+	{"PATCH", "/authorizations/:id"},
+	{"DELETE", "/authorizations/:id"},
+	{"GET", "/applications/:client_id/tokens/:access_token"},
+	{"DELETE", "/applications/:client_id/tokens"},
+	{"DELETE", "/applications/:client_id/tokens/:access_token"},
+
+	// Activity
+	{"GET", "/events"},
+	{"GET", "/repos/:owner/:repo/events"},
+	{"GET", "/networks/:owner/:repo/events"},
+	{"GET", "/orgs/:org/events"},
+	{"GET", "/users/:user/received_events"},
+	{"GET", "/users/:user/received_events/public"},
+	{"GET", "/users/:user/events"},
+	{"GET", "/users/:user/events/public"},
+	{"GET", "/users/:user/events/orgs/:org"},
+	{"GET", "/feeds"},
+	{"GET", "/notifications"},
+	{"GET", "/repos/:owner/:repo/notifications"},
+	{"PUT", "/notifications"},
+	{"PUT", "/repos/:owner/:repo/notifications"},
+	{"GET", "/notifications/threads/:id"},
+	{"PATCH", "/notifications/threads/:id"},
+	{"GET", "/notifications/threads/:id/subscription"},
+	{"PUT", "/notifications/threads/:id/subscription"},
+	{"DELETE", "/notifications/threads/:id/subscription"},
+	{"GET", "/repos/:owner/:repo/stargazers"},
+	{"GET", "/users/:user/starred"},
+	{"GET", "/user/starred"},
+	{"GET", "/user/starred/:owner/:repo"},
+	{"PUT", "/user/starred/:owner/:repo"},
+	{"DELETE", "/user/starred/:owner/:repo"},
+	{"GET", "/repos/:owner/:repo/subscribers"},
+	{"GET", "/users/:user/subscriptions"},
+	{"GET", "/user/subscriptions"},
+	{"GET", "/repos/:owner/:repo/subscription"},
+	{"PUT", "/repos/:owner/:repo/subscription"},
+	{"DELETE", "/repos/:owner/:repo/subscription"},
+	{"GET", "/user/subscriptions/:owner/:repo"},
+	{"PUT", "/user/subscriptions/:owner/:repo"},
+	{"DELETE", "/user/subscriptions/:owner/:repo"},
+
+	// Gists
+	{"GET", "/users/:user/gists"},
+	{"GET", "/gists"},
+	{"GET", "/gists/public"},
+	{"GET", "/gists/starred"},
+	{"GET", "/gists/:id"},
+	{"POST", "/gists"},
+	{"PATCH", "/gists/:id"},
+	{"PUT", "/gists/:id/star"},
+	{"DELETE", "/gists/:id/star"},
+	{"GET", "/gists/:id/star"},
+	{"POST", "/gists/:id/forks"},
+	{"DELETE", "/gists/:id"},
+
+	// Git Data
+	{"GET", "/repos/:owner/:repo/git/blobs/:sha"},
+	{"POST", "/repos/:owner/:repo/git/blobs"},
+	{"GET", "/repos/:owner/:repo/git/commits/:sha"},
+	{"POST", "/repos/:owner/:repo/git/commits"},
+	{"GET", "/repos/:owner/:repo/git/refs/*ref"},
+	{"GET", "/repos/:owner/:repo/git/refs"},
+	{"POST", "/repos/:owner/:repo/git/refs"},
+	{"PATCH", "/repos/:owner/:repo/git/refs/*ref"},
+	{"DELETE", "/repos/:owner/:repo/git/refs/*ref"},
+	{"GET", "/repos/:owner/:repo/git/tags/:sha"},
+	{"POST", "/repos/:owner/:repo/git/tags"},
+	{"GET", "/repos/:owner/:repo/git/trees/:sha"},
+	{"POST", "/repos/:owner/:repo/git/trees"},
+
+	// Issues
+	{"GET", "/issues"},
+	{"GET", "/user/issues"},
+	{"GET", "/orgs/:org/issues"},
+	{"GET", "/repos/:owner/:repo/issues"},
+	{"GET", "/repos/:owner/:repo/issues/:number"},
+	{"POST", "/repos/:owner/:repo/issues"},
+	{"PATCH", "/repos/:owner/:repo/issues/:number"},
+	{"GET", "/repos/:owner/:repo/assignees"},
+	{"GET", "/repos/:owner/:repo/assignees/:assignee"},
+	{"GET", "/repos/:owner/:repo/issues/:number/comments"},
+	{"POST", "/repos/:owner/:repo/issues/:number/comments"},
+	{"GET", "/repos/:owner/:repo/issues/:number/events"},
+	{"GET", "/repos/:owner/:repo/labels"},
+	{"GET", "/repos/:owner/:repo/labels/:name"},
+	{"POST", "/repos/:owner/:repo/labels"},
+	{"PATCH", "/repos/:owner/:repo/labels/:name"},
+	{"DELETE", "/repos/:owner/:repo/labels/:name"},
+	{"GET", "/repos/:owner/:repo/issues/:number/labels"},
+	{"POST", "/repos/:owner/:repo/issues/:number/labels"},
+	{"DELETE", "/repos/:owner/:repo/issues/:number/labels/:name"},
+	{"PUT", "/repos/:owner/:repo/issues/:number/labels"},
+	{"DELETE", "/repos/:owner/:repo/issues/:number/labels"},
+	{"GET", "/repos/:owner/:repo/milestones/:number/labels"},
+	{"GET", "/repos/:owner/:repo/milestones"},
+	{"GET", "/repos/:owner/:repo/milestones/:number"},
+	{"POST", "/repos/:owner/:repo/milestones"},
+	{"PATCH", "/repos/:owner/:repo/milestones/:number"},
+	{"DELETE", "/repos/:owner/:repo/milestones/:number"},
+
+	// Miscellaneous
+	{"GET", "/emojis"},
+	{"GET", "/gitignore/templates"},
+	{"GET", "/gitignore/templates/:name"},
+	{"POST", "/markdown"},
+	{"POST", "/markdown/raw"},
+	{"GET", "/meta"},
+	{"GET", "/rate_limit"},
+
+	// Organizations
+	{"GET", "/users/:user/orgs"},
+	{"GET", "/user/orgs"},
+	{"GET", "/orgs/:org"},
+	{"PATCH", "/orgs/:org"},
+	{"GET", "/orgs/:org/members"},
+	{"GET", "/orgs/:org/members/:user"},
+	{"DELETE", "/orgs/:org/members/:user"},
+	{"GET", "/orgs/:org/public_members"},
+	{"GET", "/orgs/:org/public_members/:user"},
+	{"PUT", "/orgs/:org/public_members/:user"},
+	{"DELETE", "/orgs/:org/public_members/:user"},
+	{"GET", "/orgs/:org/teams"},
+	{"GET", "/teams/:id"},
+	{"POST", "/orgs/:org/teams"},
+	{"PATCH", "/teams/:id"},
+	{"DELETE", "/teams/:id"},
+	{"GET", "/teams/:id/members"},
+	{"GET", "/teams/:id/members/:user"},
+	{"PUT", "/teams/:id/members/:user"},
+	{"DELETE", "/teams/:id/members/:user"},
+	{"GET", "/teams/:id/repos"},
+	{"GET", "/teams/:id/repos/:owner/:repo"},
+	{"PUT", "/teams/:id/repos/:owner/:repo"},
+	{"DELETE", "/teams/:id/repos/:owner/:repo"},
+	{"GET", "/user/teams"},
+
+	// Pull Requests
+	{"GET", "/repos/:owner/:repo/pulls"},
+	{"GET", "/repos/:owner/:repo/pulls/:number"},
+	{"POST", "/repos/:owner/:repo/pulls"},
+	{"PATCH", "/repos/:owner/:repo/pulls/:number"},
+	{"GET", "/repos/:owner/:repo/pulls/:number/commits"},
+	{"GET", "/repos/:owner/:repo/pulls/:number/files"},
+	{"GET", "/repos/:owner/:repo/pulls/:number/merge"},
+	{"PUT", "/repos/:owner/:repo/pulls/:number/merge"},
+	{"GET", "/repos/:owner/:repo/pulls/:number/comments"},
+	{"PUT", "/repos/:owner/:repo/pulls/:number/comments"},
+
+	// Repositories
+	{"GET", "/user/repos"},
+	{"GET", "/users/:user/repos"},
+	{"GET", "/orgs/:org/repos"},
+	{"GET", "/repositories"},
+	{"POST", "/user/repos"},
+	{"POST", "/orgs/:org/repos"},
+	{"GET", "/repos/:owner/:repo"},
+	{"PATCH", "/repos/:owner/:repo"},
+	{"GET", "/repos/:owner/:repo/contributors"},
+	{"GET", "/repos/:owner/:repo/languages"},
+	{"GET", "/repos/:owner/:repo/teams"},
+	{"GET", "/repos/:owner/:repo/tags"},
+	{"GET", "/repos/:owner/:repo/branches"},
+	{"GET", "/repos/:owner/:repo/branches/:branch"},
+	{"DELETE", "/repos/:owner/:repo"},
+	{"GET", "/repos/:owner/:repo/collaborators"},
+	{"GET", "/repos/:owner/:repo/collaborators/:user"},
+	{"PUT", "/repos/:owner/:repo/collaborators/:user"},
+	{"DELETE", "/repos/:owner/:repo/collaborators/:user"},
+	{"GET", "/repos/:owner/:repo/comments"},
+	{"GET", "/repos/:owner/:repo/commits/:sha/comments"},
+	{"POST", "/repos/:owner/:repo/commits/:sha/comments"},
+	{"GET", "/repos/:owner/:repo/comments/:id"},
+	{"PATCH", "/repos/:owner/:repo/comments/:id"},
+	{"DELETE", "/repos/:owner/:repo/comments/:id"},
+	{"GET", "/repos/:owner/:repo/commits"},
+	{"GET", "/repos/:owner/:repo/commits/:sha"},
+	{"GET", "/repos/:owner/:repo/readme"},
+	{"GET", "/repos/:owner/:repo/contents/*path"},
+	{"PUT", "/repos/:owner/:repo/contents/*path"},
+	{"DELETE", "/repos/:owner/:repo/contents/*path"},
+	{"GET", "/repos/:owner/:repo/:archive_format/:ref"},
+	{"GET", "/repos/:owner/:repo/keys"},
+	{"GET", "/repos/:owner/:repo/keys/:id"},
+	{"POST", "/repos/:owner/:repo/keys"},
+	{"PATCH", "/repos/:owner/:repo/keys/:id"},
+	{"DELETE", "/repos/:owner/:repo/keys/:id"},
+	{"GET", "/repos/:owner/:repo/downloads"},
+	{"GET", "/repos/:owner/:repo/downloads/:id"},
+	{"DELETE", "/repos/:owner/:repo/downloads/:id"},
+	{"GET", "/repos/:owner/:repo/forks"},
+	{"POST", "/repos/:owner/:repo/forks"},
+	{"GET", "/repos/:owner/:repo/hooks"},
+	{"GET", "/repos/:owner/:repo/hooks/:id"},
+	{"POST", "/repos/:owner/:repo/hooks"},
+	{"PATCH", "/repos/:owner/:repo/hooks/:id"},
+	{"POST", "/repos/:owner/:repo/hooks/:id/tests"},
+	{"DELETE", "/repos/:owner/:repo/hooks/:id"},
+	{"POST", "/repos/:owner/:repo/merges"},
+	{"GET", "/repos/:owner/:repo/releases"},
+	{"GET", "/repos/:owner/:repo/releases/:id"},
+	{"POST", "/repos/:owner/:repo/releases"},
+	{"PATCH", "/repos/:owner/:repo/releases/:id"},
+	{"DELETE", "/repos/:owner/:repo/releases/:id"},
+	{"GET", "/repos/:owner/:repo/releases/:id/assets"},
+	{"GET", "/repos/:owner/:repo/stats/contributors"},
+	{"GET", "/repos/:owner/:repo/stats/commit_activity"},
+	{"GET", "/repos/:owner/:repo/stats/code_frequency"},
+	{"GET", "/repos/:owner/:repo/stats/participation"},
+	{"GET", "/repos/:owner/:repo/stats/punch_card"},
+	{"GET", "/repos/:owner/:repo/statuses/:ref"},
+	{"POST", "/repos/:owner/:repo/statuses/:ref"},
+
+	// Search
+	{"GET", "/search/repositories"},
+	{"GET", "/search/code"},
+	{"GET", "/search/issues"},
+	{"GET", "/search/users"},
+	{"GET", "/legacy/issues/search/:owner/:repository/:state/:keyword"},
+	{"GET", "/legacy/repos/search/:keyword"},
+	{"GET", "/legacy/user/search/:keyword"},
+	{"GET", "/legacy/user/email/:email"},
+
+	// Users
+	{"GET", "/users/:user"},
+	{"GET", "/user"},
+	{"PATCH", "/user"},
+	{"GET", "/users"},
+	{"GET", "/user/emails"},
+	{"POST", "/user/emails"},
+	{"DELETE", "/user/emails"},
+	{"GET", "/users/:user/followers"},
+	{"GET", "/user/followers"},
+	{"GET", "/users/:user/following"},
+	{"GET", "/user/following"},
+	{"GET", "/user/following/:user"},
+	{"GET", "/users/:user/following/:target_user"},
+	{"PUT", "/user/following/:user"},
+	{"DELETE", "/user/following/:user"},
+	{"GET", "/users/:user/keys"},
+	{"GET", "/user/keys"},
+	{"GET", "/user/keys/:id"},
+	{"POST", "/user/keys"},
+	{"PATCH", "/user/keys/:id"},
+	{"DELETE", "/user/keys/:id"},
+}
+
+func newGithubRouter() *router {
+	r := newRouter()
+	for _, route := range githubAPI {
+		r.addRouter(route.method, route.path, nil)
+	}
+	return r
+}
+
+func TestTreeGithubAPI(t *testing.T) {
+	r := newGithubRouter()
+
+	// every pattern matches itself, the wildcards capture their own names
+	params := make(Params, 0, r.maxParams)
+	for _, route := range githubAPI {
+		params = params[:0]
+		n := r.getRouter(route.method, route.path, &params)
+		if assert.NotNil(t, n, route.path) {
+			assert.Equal(t, route.path, n.pattern)
+		}
+		assert.Equal(t, countParams(route.path), len(params), route.path)
+	}
+}
+
+func TestTreeRemove(t *testing.T) {
+	r := newGithubRouter()
+	orig := newGithubRouter()
+
+	// removing from a clone leaves the original intact
+	for i, route := range githubAPI {
+		if i%2 == 0 {
+			r = r.clone(route.method)
+			assert.True(t, r.removeRouter(route.method, route.path), route.path)
+			assert.False(t, r.removeRouter(route.method, route.path), route.path)
+		}
+	}
+	assert.False(t, r.removeRouter("GET", "/not/registered"))
+
+	params := make(Params, 0, r.maxParams)
+	for i, route := range githubAPI {
+		params = params[:0]
+		n := r.getRouter(route.method, route.path, &params)
+		if i%2 == 0 {
+			if n != nil {
+				// a remaining route may match the removed pattern
+				assert.NotEqual(t, route.path, n.pattern, route.path)
+			}
+		} else if assert.NotNil(t, n, route.path) {
+			assert.Equal(t, route.path, n.pattern)
+		}
+
+		params = params[:0]
+		n = orig.getRouter(route.method, route.path, &params)
+		if assert.NotNil(t, n, route.path) {
+			assert.Equal(t, route.path, n.pattern)
+		}
+	}
+
+	// the priorities still count the routes below each node
+	var check func(n *node) uint32
+	check = func(n *node) uint32 {
+		var prio uint32
+		if n.pattern != "" {
+			prio++
+		}
+		for i, child := range n.children {
+			prio += check(child)
+			if i > 0 {
+				assert.GreaterOrEqual(t, n.children[i-1].priority, child.priority)
+			}
+		}
+		for _, child := range n.wildChildren {
+			prio += check(child)
+		}
+		if n.catchAll != nil {
+			prio += check(n.catchAll)
+		}
+		assert.Equal(t, prio, n.priority, n.path)
+		return prio
+	}
+	for _, root := range r.roots {
+		check(root)
+	}
+}
+
+func TestTreeStaticBeforeParam(t *testing.T) {
+	assert := assert.New(t)
+	r := newGithubRouter()
+
+	params := make(Params, 0, r.maxParams)
+	n := r.getRouter(http.MethodGet, "/gists/starred", &params)
+	assert.Equal("/gists/starred", n.pattern)
+	assert.Empty(params)
+
+	n = r.getRouter(http.MethodGet, "/gists/starredx", &params)
+	assert.Equal("/gists/:id", n.pattern)
+	assert.Equal(Params{{Key: "id", Value: "starredx"}}, params)
+
+	// backtracks out of the static "releases" branch into the params
+	params = params[:0]
+	n = r.getRouter(http.MethodGet, "/repos/gee/gee/releases/latest", &params)
+	assert.Equal("/repos/:owner/:repo/releases/:id", n.pattern)
+
+	params = params[:0]
+	n = r.getRouter(http.MethodGet, "/repos/gee/gee/zipball/master", &params)
+	assert.Equal("/repos/:owner/:repo/:archive_format/:ref", n.pattern)
+	assert.Equal(Params{
+		{Key: "owner", Value: "gee"},
+		{Key: "repo", Value: "gee"},
+		{Key: "archive_format", Value: "zipball"},
+		{Key: "ref", Value: "master"},
+	}, params)
+
+	params = params[:0]
+	n = r.getRouter(http.MethodGet, "/repos/gee/gee/git/refs/heads/master", &params)
+	assert.Equal("/repos/:owner/:repo/git/refs/*ref", n.pattern)
+	assert.Equal(Param{Key: "ref", Value: "heads/master"}, params[2])
+
+	params = params[:0]
+	assert.Nil(r.getRouter(http.MethodGet, "/users//repos", &params))
+	assert.Nil(r.getRouter(http.MethodGet, "/notfound", &params))
+}
+
+func TestTreeChildPriority(t *testing.T) {
+	root := &node{}
+	root.insert("/a", nil)
+	root.insert("/b/1", nil)
+	root.insert("/b/2", nil)
+	root.insert("/c", nil)
+
+	slash := root.children[0]
+	assert.Equal(t, "/", slash.path)
+	assert.Equal(t, "bac", slash.indices)
+	assert.Equal(t, uint32(2), slash.children[0].priority)
+}
+
+func TestTreeConflicts(t *testing.T) {
+	tests := []struct {
+		routes []string
+		panic  string
+	}{
+		{
+			[]string{"/users/:id", "/users/:name/posts"},
+			"':name' in new path '/users/:name/posts' conflicts with existing wildcard ':id'",
+		},
+		{
+			[]string{"/files/*path", "/files/*name"},
+			"'*name' in new path '/files/*name' conflicts with existing wildcard '*path'",
+		},
+		{
+			[]string{"/users/:id", "/users/:id"},
+			"handlers are already registered for path '/users/:id'",
+		},
+		{
+			[]string{"/name/*id/*"},
+			"catch-all routes are only allowed at the end of the path in path '/name/*id/*'",
+		},
+		{
+			[]string{"/users/:/posts"},
+			"wildcards must be named with a non-empty name in path '/users/:/posts'",
+		},
+		{
+			[]string{"/users/:id:name"},
+			"only one wildcard per path segment is allowed, has: ':id:name' in path '/users/:id:name'",
+		},
+		{
+			[]string{"/files/readme", "/files/*path"},
+			"catch-all '*path' in new path '/files/*path' conflicts with existing static children",
+		},
+		{
+			[]string{"/files/*path", "/files/readme"},
+			"'readme' in new path '/files/readme' conflicts with existing catch-all '*path'",
+		},
+		{
+			[]string{"/users/:id<[>"},
+			"invalid constraint in wildcard ':id<[>' in path '/users/:id<[>': " +
+				"error parsing regexp: missing closing ]: `[)$`",
+		},
+		{
+			[]string{"/user_:id"},
+			"wildcards must start a path segment, has: 'user_:id' in path '/user_:id'",
+		},
+		{
+			[]string{"/files/a*x/meta"},
+			"wildcards must start a path segment, has: 'a*x' in path '/files/a*x/meta'",
+		},
+		{
+			[]string{"/users/:id/v:version"},
+			"wildcards must start a path segment, has: 'v:version' in path '/users/:id/v:version'",
+		},
+		{
+			[]string{"/files/:id", "/files/*path"},
+			"catch-all '*path' in new path '/files/*path' conflicts with existing wildcard ':id'",
+		},
+		{
+			[]string{"/files/*path", "/files/:id<int>"},
+			"':id<int>' in new path '/files/:id<int>' conflicts with existing catch-all '*path'",
+		},
+	}
+
+	for _, tt := range tests {
+		root := &node{}
+		last := len(tt.routes) - 1
+		for _, route := range tt.routes[:last] {
+			root.insert(route, nil)
+		}
+		assert.PanicsWithValue(t, tt.panic, func() {
+			root.insert(tt.routes[last], nil)
+		})
+	}
+
+	// the same pieces without a conflict
+	root := &node{}
+	assert.NotPanics(t, func() {
+		root.insert("/users/:id", nil)
+		root.insert("/users/:id/posts", nil)
+		root.insert("/users/new", nil)
+		root.insert("/files/*path", nil)
+		root.insert("/filesystem", nil)
+		root.insert("/static/*", nil)
+	})
+}
+
+func TestTreeParamConstraints(t *testing.T) {
+	assert := assert.New(t)
+	r := newRouter()
+	r.addRouter("GET", "/users/:id<int>", nil)
+	r.addRouter("GET", "/users/:slug<[a-z0-9-]+>/posts", nil)
+	r.addRouter("GET", "/users/:name", nil)
+	r.addRouter("GET", "/items/:uuid<uuid>", nil)
+
+	tests := []struct {
+		path    string
+		pattern string
+		params  Params
+	}{
+		{"/users/42", "/users/:id<int>", Params{{"id", "42"}}},
+		{"/users/-7", "/users/:id<int>", Params{{"id", "-7"}}},
+		{"/users/gee-web/posts", "/users/:slug<[a-z0-9-]+>/posts", Params{{"slug", "gee-web"}}},
+		{"/users/42/posts", "/users/:slug<[a-z0-9-]+>/posts", Params{{"slug", "42"}}},
+		{"/users/Gee", "/users/:name", Params{{"name", "Gee"}}},
+		{"/items/0b6a3c6e-8f2a-4bd4-9a5e-2f3c1d6e7a8B", "/items/:uuid<uuid>",
+			Params{{"uuid", "0b6a3c6e-8f2a-4bd4-9a5e-2f3c1d6e7a8B"}}},
+		{"/users/Gee/posts", "", nil},
+		{"/items/0b6a3c6e", "", nil},
+	}
+
+	for _, tt := range tests {
+		params := make(Params, 0, r.maxParams)
+		n := r.getRouter("GET", tt.path, &params)
+		if tt.pattern == "" {
+			assert.Nil(n, tt.path)
+			continue
+		}
+		if assert.NotNil(n, tt.path) {
+			assert.Equal(tt.pattern, n.pattern)
+			assert.Equal(tt.params, params)
+		}
+	}
+
+	// the same constraint with another name conflicts, another constraint does not
+	assert.Panics(func() { r.addRouter("GET", "/users/:uid<int>/posts", nil) })
+	assert.NotPanics(func() { r.addRouter("GET", "/users/:hex<[0-9a-f]+>/keys", nil) })
+	assert.Panics(func() { r.addRouter("GET", "/bad/:id<[a-z>", nil) })
+}
+
+func TestTreeLookupZeroAlloc(t *testing.T) {
+	r := newGithubRouter()
+	params := make(Params, 0, r.maxParams)
+	for _, route := range githubAPI {
+		allocs := testing.AllocsPerRun(100, func() {
+			params = params[:0]
+			r.getRouter(route.method, route.path, &params)
+		})
+		assert.Equal(t, float64(0), allocs, route.path)
+	}
+}
+
+func benchmarkRoutes(b *testing.B, r *router, routes []testRoute) {
+	params := make(Params, 0, r.maxParams)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, route := range routes {
+			params = params[:0]
+			r.getRouter(route.method, route.path, &params)
+		}
+	}
+}
+
+func BenchmarkGithubStatic(b *testing.B) {
+	benchmarkRoutes(b, newGithubRouter(), []testRoute{{"GET", "/user/repos"}})
+}
+
+func BenchmarkGithubParam(b *testing.B) {
+	benchmarkRoutes(b, newGithubRouter(), []testRoute{{"GET", "/repos/julienschmidt/httprouter/stargazers"}})
+}
+
+func BenchmarkGithubAll(b *testing.B) {
+	benchmarkRoutes(b, newGithubRouter(), githubAPI)
+}